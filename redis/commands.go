@@ -37,8 +37,10 @@ package redis
 // 🍺
 
 import (
-	"errors"
+	"math/rand"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 )
 
@@ -51,65 +53,194 @@ func (c *Client) Append(key, value string) (int, error) {
 	return iface2int(v)
 }
 
-// http://redis.io/commands/bgrewriteaof
-// BgRewriteAOF is not fully supported on sharded connections.
-func (c *Client) BgRewriteAOF() (string, error) {
-	v, err := c.execOnFirst(false, "BGREWRITEAOF")
+// bgOnAll executes a background command such as BGSAVE once on every
+// redis instance, and returns the status reply. All servers reply with the
+// same status.
+func (c *Client) bgOnAll(cmd string) (string, error) {
+	r, err := c.execOnInstances(false, cmd)
 	if err != nil {
 		return "", err
 	}
-	return iface2str(v)
+	for _, v := range r {
+		return iface2str(v)
+	}
+	return "", ErrServerError
+}
+
+// http://redis.io/commands/bgrewriteaof
+// BgRewriteAOF starts an AOF rewrite on every server.
+func (c *Client) BgRewriteAOF() (string, error) {
+	return c.bgOnAll("BGREWRITEAOF")
 }
 
 // http://redis.io/commands/bgsave
-// BgSave is not fully supported on sharded connections.
+// BgSave starts a background save on every server.
 func (c *Client) BgSave() (string, error) {
-	v, err := c.execOnFirst(false, "BGSAVE")
-	if err != nil {
-		return "", err
-	}
-	return iface2str(v)
+	return c.bgOnAll("BGSAVE")
 }
 
 // http://redis.io/commands/ping
-// goes to first connection only
+// Ping pings every server, and returns ServerErrors if any of them fail.
 func (c *Client) Ping() error {
-	v, err := c.execOnFirst(false, "PING")
+	r, err := c.execOnAll(false, "PING")
 	if err != nil {
 		return err
 	}
-	s, err := iface2str(v)
-	if err != nil {
-		return err
-	} else if s != "PONG" {
-		return ErrServerError
+	for _, v := range r {
+		s, err := iface2str(v)
+		if err != nil {
+			return err
+		} else if s != "PONG" {
+			return ErrServerError
+		}
 	}
 	return nil
 }
 
 // http://redis.io/commands/bitcount
-// BitCount ignores start and end if start is a negative number.
+// BitCount counts the bits set in the bytes from start to end, inclusive.
+// Negative offsets count from the end of the string, e.g. -1 is the last
+// byte. See BitCountAll to count the whole string.
 func (c *Client) BitCount(key string, start, end int) (int, error) {
-	var (
-		v   interface{}
-		err error
-	)
-	if start > -1 {
-		v, err = c.execWithKey(true, "BITCOUNT", key, start, end)
-	} else {
-		v, err = c.execWithKey(true, "BITCOUNT", key)
+	v, err := c.execWithKey(true, "BITCOUNT", key, start, end)
+	if err != nil {
+		return 0, err
 	}
+	return iface2int(v)
+}
+
+// BitCountAll counts the bits set in the whole string.
+func (c *Client) BitCountAll(key string) (int, error) {
+	v, err := c.execWithKey(true, "BITCOUNT", key)
 	if err != nil {
 		return 0, err
 	}
 	return iface2int(v)
 }
 
+// BitFieldOps is a list of operations of BITFIELD, built by chaining its
+// methods. The zero value is an empty list.
+//
+// Example:
+//
+//	ops := new(redis.BitFieldOps).Overflow("SAT").IncrBy("u8", 0, 10).Get("u4", 8)
+//	v, err := rc.BitField("mykey", ops)
+type BitFieldOps struct {
+	a []interface{}
+}
+
+// Get reads the integer of type typ, e.g. "i5" or "u8", at the bit offset.
+func (o *BitFieldOps) Get(typ string, offset int) *BitFieldOps {
+	o.a = append(o.a, "GET", typ, offset)
+	return o
+}
+
+// Set writes the integer of type typ at the bit offset. Its result is the
+// old value.
+func (o *BitFieldOps) Set(typ string, offset, value int) *BitFieldOps {
+	o.a = append(o.a, "SET", typ, offset, value)
+	return o
+}
+
+// IncrBy increments the integer of type typ at the bit offset. Its result
+// is the new value.
+func (o *BitFieldOps) IncrBy(typ string, offset, increment int) *BitFieldOps {
+	o.a = append(o.a, "INCRBY", typ, offset, increment)
+	return o
+}
+
+// Overflow sets how the following Set and IncrBy operations handle
+// overflows: "WRAP" (default), "SAT" or "FAIL". Failed operations have a
+// nil result.
+func (o *BitFieldOps) Overflow(mode string) *BitFieldOps {
+	o.a = append(o.a, "OVERFLOW", mode)
+	return o
+}
+
+// http://redis.io/commands/bitfield
+// BitField executes ops on the string stored at key, and returns the
+// result of each Get, Set and IncrBy in the same order. A nil ops is the
+// same as no operations.
+func (c *Client) BitField(key string, ops *BitFieldOps) ([]*int, error) {
+	return c.bitfield("BITFIELD", key, ops)
+}
+
+// http://redis.io/commands/bitfield_ro
+// BitFieldRO is the read-only version of BitField, which can be served by
+// replicas. Ops must only have Get operations. It requires redis 6.0 or
+// newer.
+func (c *Client) BitFieldRO(key string, ops *BitFieldOps) ([]*int, error) {
+	return c.bitfield("BITFIELD_RO", key, ops)
+}
+
+// bitfield executes BITFIELD or BITFIELD_RO.
+func (c *Client) bitfield(cmd, key string, ops *BitFieldOps) ([]*int, error) {
+	var a []interface{}
+	if ops != nil {
+		a = ops.a
+	}
+	v, err := c.execWithKey(true, cmd, key, a...)
+	if err != nil {
+		return nil, err
+	}
+	items, _ := v.([]interface{})
+	r := make([]*int, len(items))
+	for n, item := range items {
+		if item == nil {
+			continue
+		}
+		i, err := iface2int(item)
+		if err != nil {
+			return nil, err
+		}
+		r[n] = &i
+	}
+	return r, nil
+}
+
 // http://redis.io/commands/bitop
-// BitOp is not fully supported on sharded connections.
+// BitOp performs the operation "AND", "OR", "XOR" or "NOT" between keys,
+// stores the result in destkey, and returns its length. NOT takes a single
+// key. All keys must be on the same server.
 func (c *Client) BitOp(operation, destkey, key string, keys ...string) (int, error) {
-	a := append([]string{"BITOP", operation, destkey, key}, keys...)
-	v, err := c.execOnFirst(true, vstr2iface(a)...)
+	keys = append([]string{destkey, key}, keys...)
+	srv, err := c.pickServerForKeys("BITOP", keys)
+	if err != nil {
+		return 0, err
+	}
+	a := append([]interface{}{"BITOP", operation}, vstr2iface(keys)...)
+	v, err := c.execWithAddr(true, srv, a...)
+	if err != nil {
+		return 0, err
+	}
+	return iface2int(v)
+}
+
+// BitRange is a range of a string, from Start to End inclusive. Negative
+// offsets count from the end of the string.
+type BitRange struct {
+	Start int
+	End   int
+
+	// Bit makes Start and End bit offsets instead of byte offsets.
+	// It requires redis 7.0 or newer.
+	Bit bool
+}
+
+// http://redis.io/commands/bitpos
+// BitPos returns the offset of the first bit set to bit (0 or 1) in the
+// string, within r if not nil. It returns -1 if no bit is found. Searching
+// for 0 in the whole string returns the first bit after it if all of its
+// bits are set, but not within a range.
+func (c *Client) BitPos(key string, bit int, r *BitRange) (int, error) {
+	a := []interface{}{bit}
+	if r != nil {
+		a = append(a, r.Start, r.End)
+		if r.Bit {
+			a = append(a, "BIT")
+		}
+	}
+	v, err := c.execWithKey(true, "BITPOS", key, a...)
 	if err != nil {
 		return 0, err
 	}
@@ -119,13 +250,9 @@ func (c *Client) BitOp(operation, destkey, key string, keys ...string) (int, err
 // blbrPop supports both BLPop and BRPop.
 func (c *Client) blbrPop(cmd string, timeout int, keys ...string) (k, v string, err error) {
 	var r interface{}
-	r, err = c.execWithKeyTimeout(
-		true,
-		timeout,
-		cmd,
-		keys[0],
-		append(vstr2iface(keys[1:]), timeout)...,
-	)
+	a := append([]interface{}{cmd}, vstr2iface(keys)...)
+	r, err = c.execWithKeysTimeout(true, time.Duration(timeout)*time.Second,
+		keys, append(a, timeout)...)
 	if err != nil {
 		return
 	}
@@ -150,33 +277,25 @@ func (c *Client) blbrPop(cmd string, timeout int, keys ...string) (k, v string,
 }
 
 // http://redis.io/commands/blpop
-// BLPop is not fully supported on sharded connections.
+// Keys must be on the same server.
 // A timeout of 0 uses DefaultTimeout, which is probably too low.
 func (c *Client) BLPop(timeout int, keys ...string) (k, v string, err error) {
 	return c.blbrPop("BLPOP", timeout, keys...)
 }
 
 // http://redis.io/commands/brpop
-// BRPop is not fully supported on sharded connections.
+// Keys must be on the same server.
 // A timeout of 0 uses DefaultTimeout, which is probably too low.
 func (c *Client) BRPop(timeout int, keys ...string) (k, v string, err error) {
 	return c.blbrPop("BRPOP", timeout, keys...)
 }
 
 // http://redis.io/commands/brpoplpush
-// BRPopLPush is not fully supported on sharded connections.
+// BRPopLPush is the blocking version of RPopLPush.
 // A timeout of 0 uses DefaultTimeout, which is probably too low.
 func (c *Client) BRPopLPush(src, dst string, timeout int) (string, error) {
-	t := c.Timeout
-	// Extend the client's timeout for this operation only.
-	// TODO: make sure it does not affect other concurrent calls.
-	if t == 0 {
-		c.Timeout = time.Duration(timeout)*time.Second + DefaultTimeout
-	} else {
-		c.Timeout = time.Duration(timeout)*time.Second + t
-	}
-	v, err := c.execWithKey(true, "BRPOPLPUSH", src, dst, timeout)
-	c.Timeout = t
+	v, err := c.execWithKeysTimeout(true, time.Duration(timeout)*time.Second,
+		[]string{src, dst}, "BRPOPLPUSH", src, dst, timeout)
 	if err != nil {
 		return "", err
 	} else if v == nil {
@@ -185,9 +304,12 @@ func (c *Client) BRPopLPush(src, dst string, timeout int) (string, error) {
 	return iface2str(v)
 }
 
-func (c *Client) RPopLPush(src, dst string) (string, error) {
-	v, err := c.execWithKey(true, "RPOPLPUSH", src, dst)
-	
+// http://redis.io/commands/blmove
+// BLMove is the blocking version of LMove.
+// A timeout of 0 uses DefaultTimeout, like BLPop.
+func (c *Client) BLMove(src, dst, wherefrom, whereto string, timeout int) (string, error) {
+	v, err := c.execWithKeysTimeout(true, time.Duration(timeout)*time.Second,
+		[]string{src, dst}, "BLMOVE", src, dst, wherefrom, whereto, timeout)
 	if err != nil {
 		return "", err
 	} else if v == nil {
@@ -196,6 +318,100 @@ func (c *Client) RPopLPush(src, dst string) (string, error) {
 	return iface2str(v)
 }
 
+// lmpop executes LMPOP, or BLMPOP if block is true.
+func (c *Client) lmpop(block bool, timeout int, where string, count int, keys []string) (string, []string, error) {
+	a := []interface{}{"LMPOP"}
+	if block {
+		a = []interface{}{"BLMPOP", timeout}
+	}
+	a = append(a, len(keys))
+	a = append(a, vstr2iface(keys)...)
+	a = append(a, where)
+	if count > 0 {
+		a = append(a, "COUNT", count)
+	}
+	v, err := c.execWithKeysTimeout(true, time.Duration(timeout)*time.Second,
+		keys, a...)
+	if err != nil {
+		return "", nil, err
+	}
+	if v == nil {
+		if block {
+			return "", nil, ErrTimedOut
+		}
+		return "", []string{}, nil
+	}
+	items, ok := v.([]interface{})
+	if !ok || len(items) != 2 {
+		return "", nil, ErrServerError
+	}
+	k, err := iface2str(items[0])
+	if err != nil {
+		return "", nil, err
+	}
+	return k, iface2vstr(items[1]), nil
+}
+
+// http://redis.io/commands/blmpop
+// BLMPop is the blocking version of LMPop.
+// A timeout of 0 uses DefaultTimeout, like BLPop.
+func (c *Client) BLMPop(timeout int, where string, count int, keys ...string) (string, []string, error) {
+	return c.lmpop(true, timeout, where, count, keys)
+}
+
+// bzpop executes BZPOPMIN or BZPOPMAX.
+func (c *Client) bzpop(cmd string, timeout int, keys []string) (string, Z, error) {
+	a := append([]interface{}{cmd}, vstr2iface(keys)...)
+	a = append(a, timeout)
+	v, err := c.execWithKeysTimeout(true, time.Duration(timeout)*time.Second,
+		keys, a...)
+	if err != nil {
+		return "", Z{}, err
+	}
+	if v == nil {
+		return "", Z{}, ErrTimedOut
+	}
+	r := iface2vstr(v)
+	if len(r) != 3 {
+		return "", Z{}, ErrServerError
+	}
+	score, err := strconv.ParseFloat(r[2], 64)
+	if err != nil {
+		return "", Z{}, err
+	}
+	return r[0], Z{Member: r[1], Score: score}, nil
+}
+
+// http://redis.io/commands/bzpopmax
+// BZPopMax is the blocking version of ZPopMax. It returns the key and the
+// member popped. Keys must be on the same server.
+// A timeout of 0 uses DefaultTimeout, like BLPop.
+func (c *Client) BZPopMax(timeout int, keys ...string) (string, Z, error) {
+	return c.bzpop("BZPOPMAX", timeout, keys)
+}
+
+// http://redis.io/commands/bzpopmin
+// BZPopMin is the blocking version of ZPopMin. It works like BZPopMax.
+func (c *Client) BZPopMin(timeout int, keys ...string) (string, Z, error) {
+	return c.bzpop("BZPOPMIN", timeout, keys)
+}
+
+// http://redis.io/commands/rpoplpush
+// RPopLPush removes the last element of src, pushes it to dst, and returns
+// it. If src is empty, it returns an empty string. Both keys must be on the
+// same server.
+func (c *Client) RPopLPush(src, dst string) (string, error) {
+	srv, err := c.pickServerForKeys("RPOPLPUSH", []string{src, dst})
+	if err != nil {
+		return "", err
+	}
+	v, err := c.execWithAddr(true, srv, "RPOPLPUSH", src, dst)
+	if err != nil {
+		return "", err
+	}
+	return iface2str(v)
+}
+
 // http://redis.io/commands/client-kill
 // ClientKill is not fully supported on sharded connections.
 func (c *Client) ClientKill(kill_addr string) error {
@@ -240,7 +456,8 @@ func (c *Client) ClientSetName(name string) error {
 }
 
 // http://redis.io/commands/config-get
-// ConfigGet is not fully supported on sharded connections.
+// ConfigGet returns the configuration of the first server only.
+// See ConfigGetAll for sharded connections.
 func (c *Client) ConfigGet(name string) (map[string]string, error) {
 	v, err := c.execOnFirst(false, "CONFIG GET", name)
 	if err != nil {
@@ -249,42 +466,94 @@ func (c *Client) ConfigGet(name string) (map[string]string, error) {
 	return iface2strmap(v), nil
 }
 
+// ConfigGetAll returns the configuration of every redis instance, keyed by
+// server address.
+func (c *Client) ConfigGetAll(name string) (map[string]map[string]string, error) {
+	r, err := c.execOnInstances(false, "CONFIG GET", name)
+	m := make(map[string]map[string]string)
+	for addr, v := range r {
+		m[addr] = iface2strmap(v)
+	}
+	return m, err
+}
+
 // http://redis.io/commands/config-set
-// ConfigSet is not fully supported on sharded connections.
+// ConfigSet changes the configuration of every redis instance.
 func (c *Client) ConfigSet(name, value string) error {
-	v, err := c.execOnFirst(false, "CONFIG SET", name, value)
+	r, err := c.execOnInstances(false, "CONFIG SET", name, value)
 	if err != nil {
 		return err
 	}
-	switch v.(type) {
-	case string:
-		return nil
+	for _, v := range r {
+		if _, ok := v.(string); !ok {
+			return ErrServerError
+		}
 	}
-	return ErrServerError
+	return nil
 }
 
 // http://redis.io/commands/config-resetstat
-// ConfigResetStat is not fully supported on sharded connections.
+// ConfigResetStat resets the statistics of every redis instance.
 func (c *Client) ConfigResetStat() error {
-	v, err := c.execOnFirst(false, "CONFIG RESETSTAT")
+	r, err := c.execOnInstances(false, "CONFIG RESETSTAT")
 	if err != nil {
 		return err
 	}
-	switch v.(type) {
-	case string:
-		return nil
+	for _, v := range r {
+		if _, ok := v.(string); !ok {
+			return ErrServerError
+		}
 	}
-	return ErrServerError
+	return nil
+}
+
+// http://redis.io/commands/copy
+// Copy copies the value of src to dst, replacing dst if replace is true,
+// and returns true if it was copied. Both keys must be on the same server.
+// It requires redis 6.2 or newer.
+func (c *Client) Copy(src, dst string, replace bool) (bool, error) {
+	srv, err := c.pickServerForKeys("COPY", []string{src, dst})
+	if err != nil {
+		return false, err
+	}
+	a := []interface{}{"COPY", src, dst}
+	if replace {
+		a = append(a, "REPLACE")
+	}
+	v, err := c.execWithAddr(true, srv, a...)
+	if err != nil {
+		return false, err
+	}
+	return iface2bool(v)
 }
 
 // http://redis.io/commands/dbsize
-// DBSize is not fully supported on sharded connections.
+// DBSize returns the total number of keys of all servers.
 func (c *Client) DBSize() (int, error) {
-	v, err := c.execOnFirst(false, "DBSIZE")
+	r, err := c.DBSizeAll()
 	if err != nil {
 		return 0, err
 	}
-	return iface2int(v)
+	total := 0
+	for _, n := range r {
+		total += n
+	}
+	return total, nil
+}
+
+// DBSizeAll returns the number of keys of every server, keyed by server
+// address and db, like ServerErrors.
+func (c *Client) DBSizeAll() (map[string]int, error) {
+	r, err := c.execOnAll(false, "DBSIZE")
+	m := make(map[string]int)
+	for addr, v := range r {
+		n, e := iface2int(v)
+		if e != nil {
+			return nil, e
+		}
+		m[addr] = n
+	}
+	return m, err
 }
 
 // http://redis.io/commands/debug-segfault
@@ -320,40 +589,44 @@ func (c *Client) DecrBy(key string, decrement int) (int, error) {
 }
 
 // http://redis.io/commands/del
-// Del issues a plain DEL command to redis if the client is connected to a
-// single server. On sharding, it issues one DEL command per key, in the
-// server selected for each given key.
-func (c *Client) Del(keys ...string) (n int, err error) {
-	if c.selector.Sharding() {
-		n, err = c.delMulti(keys...)
-	} else {
-		n, err = c.delPlain(keys...)
-	}
-	return n, err
-}
-
-func (c *Client) delMulti(keys ...string) (int, error) {
-	deleted := 0
-	for _, key := range keys {
-		count, err := c.delPlain(key)
-		if err != nil {
-			return 0, err
-		}
-		deleted += count
-	}
-	return deleted, nil
+// Del deletes keys from all servers they are bound to, issuing one DEL
+// command per server concurrently on sharded connections.
+func (c *Client) Del(keys ...string) (int, error) {
+	return c.execSumWithKeys("DEL", keys)
 }
 
-func (c *Client) delPlain(keys ...string) (int, error) {
-	v, err := c.execWithKey(true, "DEL", keys[0], vstr2iface(keys[1:])...)
-	if err != nil {
-		return 0, err
+// http://redis.io/commands/discard
+// TODO: Discard
+
+// Do sends a command that has no wrapper, such as newer or module commands,
+// to the first server. Args may be strings, []byte, ints, int64s, uint64s
+// or float64s; other types make the Reply fail without sending anything.
+//
+// Do always runs on the first server, so when connected to multiple
+// servers, commands that take a key must be sent with DoWithKey.
+//
+// Example:
+//
+//	user, err := rc.Do("ACL", "WHOAMI").Text()
+func (c *Client) Do(cmd string, args ...interface{}) *Reply {
+	if err := checkArgs(args); err != nil {
+		return NewReply(nil, err)
 	}
-	return iface2int(v)
+	return NewReply(c.execOnFirst(true, append([]interface{}{cmd}, args...)...))
 }
 
-// http://redis.io/commands/discard
-// TODO: Discard
+// DoWithKey is like Do, but sends the command to the server of key, which
+// is sent as the first argument of the command.
+//
+// Example:
+//
+//	s, err := rc.DoWithKey("doc", "JSON.GET", "$.name").Text()
+func (c *Client) DoWithKey(key, cmd string, args ...interface{}) *Reply {
+	if err := checkArgs(args); err != nil {
+		return NewReply(nil, err)
+	}
+	return NewReply(c.execWithKey(true, cmd, key, args...))
+}
 
 // http://redis.io/commands/dump
 func (c *Client) Dump(key string) (string, error) {
@@ -374,63 +647,55 @@ func (c *Client) Echo(message string) (string, error) {
 	return iface2str(v)
 }
 
-// http://redis.io/commands/eval
-// Eval is not fully supported on sharded connections.
-func (c *Client) Eval(script string, numkeys int, keys, args []string) (interface{}, error) {
-	a := []interface{}{
-		"EVAL",
-		script, // escape?
-		numkeys,
-		//strings.Join(keys, " "),
-		//strings.Join(args, " "),
-	}
-    keysIf := make([]interface{}, len(keys))
-    for i, v := range keys {
-        keysIf[i] = interface{}(v)
-    }
-    a = append(a,keysIf...)
-    
-    argsIf := make([]interface{}, len(args))
-    for i, v := range args {
-        argsIf[i] = interface{}(v)
-    }
-    a = append(a,argsIf...)
-    
-    
-	v, err := c.execOnFirst(true, a...)
+// eval executes EVAL, EVALSHA or their read-only variants on the server
+// of keys, which must all be on the same server.
+func (c *Client) eval(cmd, script string, numkeys int, keys, args []string) (interface{}, error) {
+	srv, err := c.pickServerForKeys(cmd, keys)
 	if err != nil {
 		return nil, err
 	}
-	return v, nil
+	a := append([]interface{}{cmd, script, numkeys}, vstr2iface(keys)...)
+	a = append(a, vstr2iface(args)...)
+	return c.execWithAddr(true, srv, a...)
+}
+
+// http://redis.io/commands/eval
+// Eval runs a Lua script on the server of its keys, which must all be on
+// the same server. Scripts with no keys run on the first server. See
+// Script to avoid sending the script every time.
+func (c *Client) Eval(script string, numkeys int, keys, args []string) (interface{}, error) {
+	return c.eval("EVAL", script, numkeys, keys, args)
+}
+
+// http://redis.io/commands/eval_ro
+// EvalRO is the read-only version of Eval, which can be served by
+// replicas. It requires redis 7.0 or newer.
+func (c *Client) EvalRO(script string, numkeys int, keys, args []string) (interface{}, error) {
+	return c.eval("EVAL_RO", script, numkeys, keys, args)
 }
 
 // http://redis.io/commands/evalsha
-// EvalSha is not fully supported on sharded connections.
+// EvalSha runs a script loaded by ScriptLoad, and is routed like Eval.
 func (c *Client) EvalSha(sha1 string, numkeys int, keys, args []string) (interface{}, error) {
-	a := []interface{}{
-		"EVALSHA",
-		sha1,
-		numkeys,
-		strings.Join(keys, " "),
-		strings.Join(args, " "),
-	}
-	v, err := c.execOnFirst(true, a...)
-	if err != nil {
-		return nil, err
-	}
-	return v, nil
+	return c.eval("EVALSHA", sha1, numkeys, keys, args)
+}
+
+// http://redis.io/commands/evalsha_ro
+// EvalShaRO is the read-only version of EvalSha, which can be served by
+// replicas. It requires redis 7.0 or newer.
+func (c *Client) EvalShaRO(sha1 string, numkeys int, keys, args []string) (interface{}, error) {
+	return c.eval("EVALSHA_RO", sha1, numkeys, keys, args)
 }
 
 // http://redis.io/commands/exec
 // TODO: Exec
 
 // http://redis.io/commands/exists
-func (c *Client) Exists(key string) (bool, error) {
-	v, err := c.execWithKey(true, "EXISTS", key)
-	if err != nil {
-		return false, err
-	}
-	return iface2bool(v)
+// Exists returns the number of keys that exist, issuing one EXISTS command
+// per server concurrently on sharded connections. It used to take a single
+// key and return a bool; n, err := Exists(key) with n == 1 replaces it.
+func (c *Client) Exists(keys ...string) (int, error) {
+	return c.execSumWithKeys("EXISTS", keys)
 }
 
 // http://redis.io/commands/expire
@@ -454,21 +719,37 @@ func (c *Client) ExpireAt(key string, timestamp int) (bool, error) {
 	return iface2bool(v)
 }
 
+// http://redis.io/commands/expiretime
+// ExpireTime returns the unix time at which key expires, in seconds, -1 if
+// it has no timeout, or -2 if it does not exist. It requires redis 7.0 or
+// newer.
+func (c *Client) ExpireTime(key string) (int, error) {
+	v, err := c.execWithKey(true, "EXPIRETIME", key)
+	if err != nil {
+		return 0, err
+	}
+	return iface2int(v)
+}
+
 // http://redis.io/commands/flushall
-// FlushAll is not fully supported on sharded connections.
+// FlushAll removes all keys of all databases of every redis instance.
 func (c *Client) FlushAll() error {
-	_, err := c.execOnFirst(false, "FLUSHALL")
+	_, err := c.execOnInstances(false, "FLUSHALL")
 	return err
 }
 
-// http://redis.io/commands/flushall
-// FlushDB is not fully supported on sharded connections.
+// http://redis.io/commands/flushdb
+// FlushDB removes all keys of the selected database of every server.
 func (c *Client) FlushDB() error {
-	_, err := c.execOnFirst(false, "FLUSHDB")
+	_, err := c.execOnAll(false, "FLUSHDB")
 	return err
 }
 
 // http://redis.io/commands/get
+// Get returns the value of a key, or an empty string if the key does not
+// exist. Unlike HGet, which returns ErrNil for a missing field, Get keeps
+// returning an empty string so that existing callers are not broken; use
+// Exists to tell a missing key from an empty value.
 func (c *Client) Get(key string) (string, error) {
 	v, err := c.execWithKey(true, "GET", key)
 	if err != nil {
@@ -486,6 +767,65 @@ func (c *Client) GetBit(key string, offset int) (int, error) {
 	return iface2int(v)
 }
 
+// http://redis.io/commands/getdel
+// GetDel returns the value of a key and deletes it. If the key does not
+// exist, it returns an empty string. It requires redis 6.2 or newer.
+func (c *Client) GetDel(key string) (string, error) {
+	v, err := c.execWithKey(true, "GETDEL", key)
+	if err != nil {
+		return "", err
+	}
+	return iface2str(v)
+}
+
+// GetExArgs are the options of GETEX. At most one of them can be set.
+type GetExArgs struct {
+	EX      int  // expire in seconds
+	PX      int  // expire in milliseconds
+	EXAT    int  // expire at a unix time, in seconds
+	PXAT    int  // expire at a unix time, in milliseconds
+	Persist bool // remove the timeout
+}
+
+// args returns the options in the form of command arguments.
+func (o GetExArgs) args() []interface{} {
+	a := expiry(o.EX, o.PX, o.EXAT, o.PXAT)
+	if o.Persist {
+		a = append(a, "PERSIST")
+	}
+	return a
+}
+
+// expiry returns the expiration options shared by SET and GETEX.
+func expiry(ex, px, exat, pxat int) []interface{} {
+	var a []interface{}
+	if ex > 0 {
+		a = append(a, "EX", ex)
+	}
+	if px > 0 {
+		a = append(a, "PX", px)
+	}
+	if exat > 0 {
+		a = append(a, "EXAT", exat)
+	}
+	if pxat > 0 {
+		a = append(a, "PXAT", pxat)
+	}
+	return a
+}
+
+// http://redis.io/commands/getex
+// GetEx returns the value of a key and optionally sets or removes its
+// timeout. If the key does not exist, it returns an empty string.
+// It requires redis 6.2 or newer.
+func (c *Client) GetEx(key string, args GetExArgs) (string, error) {
+	v, err := c.execWithKey(true, "GETEX", key, args.args()...)
+	if err != nil {
+		return "", err
+	}
+	return iface2str(v)
+}
+
 // http://redis.io/commands/getrange
 func (c *Client) GetRange(key string, start, end int) (string, error) {
 	v, err := c.execWithKey(true, "GETRANGE", key, start, end)
@@ -526,14 +866,69 @@ func (c *Client) IncrBy(key string, increment int) (int, error) {
 	return iface2int(v)
 }
 
+// http://redis.io/commands/incrbyfloat
+// IncrByFloat increments the value of a key, and returns the new value.
+func (c *Client) IncrByFloat(key string, increment float64) (float64, error) {
+	v, err := c.execWithKey(true, "INCRBYFLOAT", key, increment)
+	if err != nil {
+		return 0, err
+	}
+	s, err := iface2str(v)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseFloat(s, 64)
+}
+
+// http://redis.io/commands/info
+// Info returns information about the first server only.
+// See InfoAll for sharded connections. An empty section returns the
+// default set of information.
+func (c *Client) Info(section string) (string, error) {
+	a := []interface{}{"INFO"}
+	if section != "" {
+		a = append(a, section)
+	}
+	v, err := c.execOnFirst(true, a...)
+	if err != nil {
+		return "", err
+	}
+	return iface2str(v)
+}
+
+// InfoAll returns information about every redis instance, keyed by server
+// address.
+func (c *Client) InfoAll(section string) (map[string]string, error) {
+	a := []interface{}{"INFO"}
+	if section != "" {
+		a = append(a, section)
+	}
+	r, err := c.execOnInstances(true, a...)
+	m := make(map[string]string)
+	for addr, v := range r {
+		s, e := iface2str(v)
+		if e != nil {
+			return nil, e
+		}
+		m[addr] = s
+	}
+	return m, err
+}
+
 // http://redis.io/commands/keys
+// Keys returns the keys matching pattern in all servers. It blocks each
+// server while running, and should not be used in production.
+// See Scan for an alternative.
 func (c *Client) Keys(pattern string) ([]string, error) {
 	keys := []string{}
-	v, err := c.execOnFirst(true, "KEYS", pattern)
+	r, err := c.execOnAll(true, "KEYS", pattern)
 	if err != nil {
 		return keys, err
 	}
-	return iface2vstr(v), nil
+	for _, v := range r {
+		keys = append(keys, iface2vstr(v)...)
+	}
+	return keys, nil
 }
 
 // http://redis.io/commands/lpush
@@ -596,44 +991,216 @@ func (c *Client) LRange(key string, begin, end int) ([]string, error) {
 	return iface2vstr(v), nil
 }
 
-// http://redis.io/commands/hexists
-func (c *Client) HExists(key, member string) (bool, error) {
-	v, err := c.execWithKey(true, "HEXISTS", key, member)
+// http://redis.io/commands/linsert
+// LInsert inserts value in the list stored at key, either "BEFORE" or
+// "AFTER" the first element equal to pivot. It returns the length of the
+// list, -1 if pivot was not found, or 0 if the list does not exist.
+func (c *Client) LInsert(key, where, pivot, value string) (int, error) {
+	v, err := c.execWithKey(true, "LINSERT", key, where, pivot, value)
 	if err != nil {
-		return false, err
+		return 0, err
 	}
-	return iface2bool(v)
+	return iface2int(v)
 }
 
-
-// http://redis.io/commands/hget
-func (c *Client) HGet(key, member string) (string, error) {
-	v, err := c.execWithKey(true, "HGET", key, member)
+// http://redis.io/commands/lmove
+// LMove removes the first ("LEFT") or last ("RIGHT") element of src,
+// pushes it to the head or tail of dst, and returns it. If src is empty,
+// it returns an empty string. Both keys must be on the same server. It
+// requires redis 6.2 or newer.
+func (c *Client) LMove(src, dst, wherefrom, whereto string) (string, error) {
+	srv, err := c.pickServerForKeys("LMOVE", []string{src, dst})
+	if err != nil {
+		return "", err
+	}
+	v, err := c.execWithAddr(true, srv, "LMOVE", src, dst, wherefrom, whereto)
 	if err != nil {
 		return "", err
 	}
 	return iface2str(v)
 }
 
-// http://redis.io/commands/hdel
-func (c *Client) HDel(key, member string) (int, error) {
-	v, err := c.execWithKey(true, "HDEL", key, member)
-	if err != nil {
-		return 0, err
-	}
-	return iface2int(v)
+// http://redis.io/commands/lmpop
+// LMPop pops up to count elements from the "LEFT" or "RIGHT" of the first
+// non-empty list of keys, and returns its key and the elements. If count
+// is zero, one element is popped. If all lists are empty, it returns an
+// empty key. Keys must be on the same server.
+// It requires redis 7.0 or newer.
+func (c *Client) LMPop(where string, count int, keys ...string) (string, []string, error) {
+	return c.lmpop(false, 0, where, count, keys)
 }
 
-// http://redis.io/commands/hgetall
-func (c *Client) HGetAll(key string) (map[string]string, error) {
-	v, err := c.execWithKey(true, "HGETALL", key)
+// http://redis.io/commands/lpop
+// LPopCount removes and returns up to count elements from the head of a
+// list. It requires redis 6.2 or newer.
+func (c *Client) LPopCount(key string, count int) ([]string, error) {
+	v, err := c.execWithKey(true, "LPOP", key, count)
 	if err != nil {
 		return nil, err
 	}
-	return iface2strmap(v), nil
+	return iface2vstr(v), nil
 }
 
-// http://redis.io/commands/hincrby
+// LPosOptions are the options of LPos and LPosCount.
+type LPosOptions struct {
+	// Rank skips the first Rank-1 matches, or searches from the tail
+	// of the list if negative. If zero, it's not sent.
+	Rank int
+
+	// MaxLen limits the number of elements compared. If zero, all
+	// elements are compared.
+	MaxLen int
+}
+
+func (o LPosOptions) args() []interface{} {
+	var a []interface{}
+	if o.Rank != 0 {
+		a = append(a, "RANK", o.Rank)
+	}
+	if o.MaxLen > 0 {
+		a = append(a, "MAXLEN", o.MaxLen)
+	}
+	return a
+}
+
+// http://redis.io/commands/lpos
+// LPos returns the index of the first element equal to element in the list
+// stored at key, or -1 if there is none. It requires redis 6.0.6 or newer.
+func (c *Client) LPos(key, element string, opts LPosOptions) (int, error) {
+	v, err := c.execWithKey(true, "LPOS", key, append([]interface{}{element}, opts.args()...)...)
+	if err != nil {
+		return 0, err
+	} else if v == nil {
+		return -1, nil
+	}
+	return iface2int(v)
+}
+
+// LPosCount is like LPos, but returns the indexes of up to count matching
+// elements. If count is zero, all matches are returned.
+func (c *Client) LPosCount(key, element string, count int, opts LPosOptions) ([]int, error) {
+	a := append([]interface{}{element, "COUNT", count}, opts.args()...)
+	v, err := c.execWithKey(true, "LPOS", key, a...)
+	if err != nil {
+		return nil, err
+	}
+	return iface2vint(v)
+}
+
+// http://redis.io/commands/lpushx
+// LPushX pushes values to the head of a list only if it exists, and
+// returns the length of the list.
+func (c *Client) LPushX(key string, values ...string) (int, error) {
+	v, err := c.execWithKey(true, "LPUSHX", key, vstr2iface(values)...)
+	if err != nil {
+		return 0, err
+	}
+	return iface2int(v)
+}
+
+// http://redis.io/commands/lrem
+// LRem removes count elements equal to value, from the head of the list if
+// count is positive, or from the tail if negative. If count is zero, all
+// of them are removed. It returns the number of elements removed.
+func (c *Client) LRem(key string, count int, value string) (int, error) {
+	v, err := c.execWithKey(true, "LREM", key, count, value)
+	if err != nil {
+		return 0, err
+	}
+	return iface2int(v)
+}
+
+// http://redis.io/commands/lset
+func (c *Client) LSet(key string, index int, value string) (err error) {
+	_, err = c.execWithKey(true, "LSET", key, index, value)
+	return
+}
+
+// http://redis.io/commands/rpop
+// RPopCount removes and returns up to count elements from the tail of a
+// list. It requires redis 6.2 or newer.
+func (c *Client) RPopCount(key string, count int) ([]string, error) {
+	v, err := c.execWithKey(true, "RPOP", key, count)
+	if err != nil {
+		return nil, err
+	}
+	return iface2vstr(v), nil
+}
+
+// http://redis.io/commands/hexists
+func (c *Client) HExists(key, member string) (bool, error) {
+	v, err := c.execWithKey(true, "HEXISTS", key, member)
+	if err != nil {
+		return false, err
+	}
+	return iface2bool(v)
+}
+
+
+// http://redis.io/commands/hexpire
+// HExpire sets a timeout on fields of a hash, which are removed when it
+// expires. It returns a code for each field: 1 if the timeout was set,
+// 2 if the field was removed because seconds is 0, or -2 if the field
+// does not exist. It requires redis 7.4 or newer.
+func (c *Client) HExpire(key string, seconds int, fields ...string) ([]int, error) {
+	return c.hfields("HEXPIRE", key, []interface{}{seconds}, fields)
+}
+
+// hfields executes one of the commands of hash fields expiration, whose
+// arguments end with FIELDS and the number of fields, and returns a code
+// for each field.
+func (c *Client) hfields(cmd, key string, a []interface{}, fields []string) ([]int, error) {
+	a = append(a, "FIELDS", len(fields))
+	a = append(a, vstr2iface(fields)...)
+	v, err := c.execWithKey(true, cmd, key, a...)
+	if err != nil {
+		return nil, err
+	}
+	if v == nil {
+		// Replied by early versions when the key does not exist.
+		r := make([]int, len(fields))
+		for n := range r {
+			r[n] = -2
+		}
+		return r, nil
+	}
+	return iface2vint(v)
+}
+
+// http://redis.io/commands/hget
+// HGet returns the value of a field, or ErrNil if the field or the hash
+// does not exist.
+func (c *Client) HGet(key, member string) (string, error) {
+	v, err := c.execWithKey(true, "HGET", key, member)
+	if err != nil {
+		return "", err
+	} else if v == nil {
+		return "", ErrNil
+	}
+	return iface2str(v)
+}
+
+// http://redis.io/commands/hdel
+// HDel removes fields from a hash, and returns the number of fields
+// removed.
+func (c *Client) HDel(key string, fields ...string) (int, error) {
+	v, err := c.execWithKey(true, "HDEL", key, vstr2iface(fields)...)
+	if err != nil {
+		return 0, err
+	}
+	return iface2int(v)
+}
+
+// http://redis.io/commands/hgetall
+func (c *Client) HGetAll(key string) (map[string]string, error) {
+	v, err := c.execWithKey(true, "HGETALL", key)
+	if err != nil {
+		return nil, err
+	}
+	return iface2strmap(v), nil
+}
+
+// http://redis.io/commands/hincrby
 func (c *Client) HIncrBy(key string, field string, increment int) (int, error) {
 	v, err := c.execWithKey(true, "HINCRBY", key, field, increment)
 	if err != nil {
@@ -642,13 +1209,62 @@ func (c *Client) HIncrBy(key string, field string, increment int) (int, error) {
 	return iface2int(v)
 }
 
+// http://redis.io/commands/hincrbyfloat
+// HIncrByFloat increments the value of a field, and returns the new value.
+func (c *Client) HIncrByFloat(key, field string, increment float64) (float64, error) {
+	v, err := c.execWithKey(true, "HINCRBYFLOAT", key, field, increment)
+	if err != nil {
+		return 0, err
+	}
+	s, err := iface2str(v)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseFloat(s, 64)
+}
+
+// http://redis.io/commands/hkeys
+func (c *Client) HKeys(key string) ([]string, error) {
+	v, err := c.execWithKey(true, "HKEYS", key)
+	if err != nil {
+		return nil, err
+	}
+	return iface2vstr(v), nil
+}
+
+// http://redis.io/commands/hlen
+func (c *Client) HLen(key string) (int, error) {
+	v, err := c.execWithKey(true, "HLEN", key)
+	if err != nil {
+		return 0, err
+	}
+	return iface2int(v)
+}
+
 // http://redis.io/commands/hmget
-func (c *Client) HMGet(key string, field ...string) ([]string, error) {
+// HMGet returns the values of fields in the same order they're given.
+// Values of fields that do not exist are nil.
+func (c *Client) HMGet(key string, field ...string) ([]*string, error) {
 	v, err := c.execWithKey(true, "HMGET", key, vstr2iface(field)...)
 	if err != nil {
 		return nil, err
 	}
-	return iface2vstr(v), nil
+	items, ok := v.([]interface{})
+	if !ok || len(items) != len(field) {
+		return nil, ErrServerError
+	}
+	r := make([]*string, len(items))
+	for n, item := range items {
+		if item == nil {
+			continue
+		}
+		s, err := iface2str(item)
+		if err != nil {
+			return nil, err
+		}
+		r[n] = &s
+	}
+	return r, nil
 }
 
 // http://redis.io/commands/hmset
@@ -665,465 +1281,1609 @@ func (c *Client) HMSet(key string, items map[string]string) (err error) {
 	return
 }
 
+// http://redis.io/commands/hpersist
+// HPersist removes the timeout of fields of a hash. It returns a code for
+// each field: 1 if the timeout was removed, -1 if the field has no timeout,
+// or -2 if the field does not exist. It requires redis 7.4 or newer.
+func (c *Client) HPersist(key string, fields ...string) ([]int, error) {
+	return c.hfields("HPERSIST", key, nil, fields)
+}
+
+// http://redis.io/commands/hrandfield
+// HRandField returns a random field of a hash, or ErrNil if the hash does
+// not exist. It requires redis 6.2 or newer.
+func (c *Client) HRandField(key string) (string, error) {
+	v, err := c.execWithKey(true, "HRANDFIELD", key)
+	if err != nil {
+		return "", err
+	} else if v == nil {
+		return "", ErrNil
+	}
+	return iface2str(v)
+}
+
+// HRandFieldCount returns up to count random fields of a hash, or fewer if
+// the hash is smaller. A negative count allows the same field to be
+// returned more than once. If the hash does not exist, no fields are
+// returned.
+func (c *Client) HRandFieldCount(key string, count int) ([]string, error) {
+	v, err := c.execWithKey(true, "HRANDFIELD", key, count)
+	if err != nil {
+		return nil, err
+	}
+	return iface2vstr(v), nil
+}
+
 // http://redis.io/commands/hset
 func (c *Client) HSet(key, field, value string) (err error) {
 	_, err = c.execWithKey(true, "HSET", key, field, value)
 	return
 }
 
-// http://redis.io/commands/zincrby
-func (c *Client) ZIncrBy(key string, increment int, member string) (string, error) {
-	v, err := c.execWithKey(true, "ZINCRBY", key, increment, member)
+// http://redis.io/commands/hsetnx
+// HSetNX sets a field only if it does not exist, and returns true if it
+// was set.
+func (c *Client) HSetNX(key, field, value string) (bool, error) {
+	v, err := c.execWithKey(true, "HSETNX", key, field, value)
 	if err != nil {
-		return "", err
+		return false, err
 	}
-	return iface2str(v)
+	return iface2bool(v)
 }
 
-// WIP (we stopped here)
-// http://redis.io/commands/mget
+// http://redis.io/commands/hstrlen
+// HStrLen returns the length of the value of a field, or 0 if the field
+// does not exist.
+func (c *Client) HStrLen(key, field string) (int, error) {
+	v, err := c.execWithKey(true, "HSTRLEN", key, field)
+	if err != nil {
+		return 0, err
+	}
+	return iface2int(v)
+}
 
-// MGet is not fully supported on sharded connections.
-// TODO: fix
-func (c *Client) MGet(keys ...string) ([]string, error) {
-	tmp := make([]interface{}, len(keys)+1)
-	tmp[0] = "MGET"
-	for n, k := range keys {
-		tmp[n+1] = k
+// http://redis.io/commands/httl
+// HTTL returns the remaining time to live of fields of a hash, in seconds.
+// It returns -1 for fields with no timeout, and -2 for fields that do not
+// exist. It requires redis 7.4 or newer.
+func (c *Client) HTTL(key string, fields ...string) ([]int, error) {
+	return c.hfields("HTTL", key, nil, fields)
+}
+
+// http://redis.io/commands/hvals
+func (c *Client) HVals(key string) ([]string, error) {
+	v, err := c.execWithKey(true, "HVALS", key)
+	if err != nil {
+		return nil, err
 	}
-	v, err := c.execOnFirst(true, tmp...)
+	return iface2vstr(v), nil
+}
+
+// http://redis.io/commands/mget
+// MGet returns the values of all keys in the same order they're given,
+// issuing one MGET command per server concurrently on sharded connections.
+// Keys that do not exist have an empty value.
+func (c *Client) MGet(keys ...string) ([]string, error) {
+	groups, err := c.groupKeys("MGET", keys)
 	if err != nil {
 		return nil, err
 	}
-	switch v.(type) {
-	case []interface{}:
-		items := v.([]interface{})
-		resp := make([]string, len(items))
+	resp := make([]string, len(keys))
+	err = c.execOnGroups(groups, func(g *keyGroup) error {
+		a := append([]interface{}{"MGET"}, vstr2iface(g.keys)...)
+		v, err := c.execWithAddr(true, g.srv, a...)
+		if err != nil {
+			return err
+		}
+		items, ok := v.([]interface{})
+		if !ok || len(items) != len(g.keys) {
+			return ErrServerError
+		}
 		for n, item := range items {
 			switch item.(type) {
 			case string:
-				resp[n] = item.(string)
+				resp[g.idx[n]] = item.(string)
 			}
 		}
-		return resp, nil
+		return nil
+	})
+	if err != nil {
+		return nil, err
 	}
-	return nil, ErrServerError
+	return resp, nil
 }
 
 // http://redis.io/commands/mset
-// MSet is not fully supported on sharded connections.
-// TODO: fix
+// MSet issues one MSET command per server concurrently on sharded
+// connections, in which case it's not atomic: some keys might be set
+// even if an error is returned.
 func (c *Client) MSet(items map[string]string) error {
-	tmp := make([]interface{}, (len(items)*2)+1)
-	tmp[0] = "MSET"
-	idx := 0
-	for k, v := range items {
-		n := idx * 2
-		tmp[n+1] = k
-		tmp[n+2] = v
-		idx++
+	keys := make([]string, 0, len(items))
+	for k := range items {
+		keys = append(keys, k)
 	}
-	_, err := c.execOnFirst(true, tmp...)
+	groups, err := c.groupKeys("MSET", keys)
 	if err != nil {
 		return err
 	}
-	return nil
+	return c.execOnGroups(groups, func(g *keyGroup) error {
+		a := make([]interface{}, 1, (len(g.keys)*2)+1)
+		a[0] = "MSET"
+		for _, k := range g.keys {
+			a = append(a, k, items[k])
+		}
+		_, err := c.execWithAddr(true, g.srv, a...)
+		return err
+	})
 }
 
-// http://redis.io/commands/publish
-func (c *Client) Publish(channel string, value string) error {
-	_, err := c.execWithKey(true, "PUBLISH", channel, value)
+// http://redis.io/commands/msetnx
+// MSetNX sets all keys only if none of them exist, and returns true if
+// they were set. All keys must be on the same server.
+func (c *Client) MSetNX(items map[string]string) (bool, error) {
+	keys := make([]string, 0, len(items))
+	a := make([]interface{}, 1, (len(items)*2)+1)
+	a[0] = "MSETNX"
+	for k, v := range items {
+		keys = append(keys, k)
+		a = append(a, k, v)
+	}
+	srv, err := c.pickServerForKeys("MSETNX", keys)
+	if err != nil {
+		return false, err
+	}
+	v, err := c.execWithAddr(true, srv, a...)
+	if err != nil {
+		return false, err
+	}
+	return iface2bool(v)
+}
+
+// http://redis.io/commands/move
+// Move moves key to another database of the same server, and returns true
+// if it was moved.
+func (c *Client) Move(key string, db int) (bool, error) {
+	v, err := c.execWithKey(true, "MOVE", key, db)
+	if err != nil {
+		return false, err
+	}
+	return iface2bool(v)
+}
+
+// object executes an OBJECT subcommand on the server of key, and returns
+// ErrNil if the key does not exist.
+func (c *Client) object(sub, key string) (interface{}, error) {
+	srv, err := c.pickServer("OBJECT", key)
+	if err != nil {
+		return nil, err
+	}
+	v, err := c.execWithAddr(true, srv, "OBJECT", sub, key)
+	if err != nil {
+		return nil, err
+	} else if v == nil {
+		return nil, ErrNil
+	}
+	return v, nil
+}
+
+// http://redis.io/commands/object-encoding
+// ObjectEncoding returns the internal encoding of the value of key, e.g.
+// "listpack" or "hashtable".
+func (c *Client) ObjectEncoding(key string) (string, error) {
+	v, err := c.object("ENCODING", key)
+	if err != nil {
+		return "", err
+	}
+	return iface2str(v)
+}
+
+// http://redis.io/commands/object-freq
+// ObjectFreq returns the access frequency of key. It requires an LFU
+// maxmemory-policy.
+func (c *Client) ObjectFreq(key string) (int, error) {
+	v, err := c.object("FREQ", key)
+	if err != nil {
+		return 0, err
+	}
+	return iface2int(v)
+}
+
+// http://redis.io/commands/object-idletime
+// ObjectIdleTime returns the time since key was last accessed. It requires
+// an LRU maxmemory-policy.
+func (c *Client) ObjectIdleTime(key string) (time.Duration, error) {
+	v, err := c.object("IDLETIME", key)
+	if err != nil {
+		return 0, err
+	}
+	n, err := iface2int(v)
+	return time.Duration(n) * time.Second, err
+}
+
+// http://redis.io/commands/persist
+// Persist removes the timeout of key, and returns true if it was removed.
+func (c *Client) Persist(key string) (bool, error) {
+	v, err := c.execWithKey(true, "PERSIST", key)
+	if err != nil {
+		return false, err
+	}
+	return iface2bool(v)
+}
+
+// http://redis.io/commands/pexpire
+// PExpire is like Expire, with millisecond precision.
+func (c *Client) PExpire(key string, timeout time.Duration) (bool, error) {
+	v, err := c.execWithKey(true, "PEXPIRE", key, ms(timeout))
+	if err != nil {
+		return false, err
+	}
+	return iface2bool(v)
+}
+
+// http://redis.io/commands/pexpireat
+// PExpireAt is like ExpireAt, with millisecond precision.
+func (c *Client) PExpireAt(key string, t time.Time) (bool, error) {
+	v, err := c.execWithKey(true, "PEXPIREAT", key, int(t.UnixNano()/int64(time.Millisecond)))
+	if err != nil {
+		return false, err
+	}
+	return iface2bool(v)
+}
+
+// http://redis.io/commands/pfadd
+// PFAdd adds elements to a HyperLogLog, and returns true if its estimated
+// cardinality changed.
+func (c *Client) PFAdd(key string, elements ...string) (bool, error) {
+	v, err := c.execWithKey(true, "PFADD", key, vstr2iface(elements)...)
+	if err != nil {
+		return false, err
+	}
+	return iface2bool(v)
+}
+
+// http://redis.io/commands/pfcount
+// PFCount returns the approximate number of unique elements added to the
+// HyperLogLogs, counting elements added to more than one of them once.
+// Keys must be on the same server.
+func (c *Client) PFCount(keys ...string) (int, error) {
+	srv, err := c.pickServerForKeys("PFCOUNT", keys)
+	if err != nil {
+		return 0, err
+	}
+	v, err := c.execWithAddr(true, srv, append([]interface{}{"PFCOUNT"}, vstr2iface(keys)...)...)
+	if err != nil {
+		return 0, err
+	}
+	return iface2int(v)
+}
+
+// http://redis.io/commands/pfmerge
+// PFMerge merges HyperLogLogs into dst, which may be one of them. All keys
+// must be on the same server.
+func (c *Client) PFMerge(dst string, keys ...string) error {
+	keys = append([]string{dst}, keys...)
+	srv, err := c.pickServerForKeys("PFMERGE", keys)
+	if err != nil {
+		return err
+	}
+	_, err = c.execWithAddr(true, srv, append([]interface{}{"PFMERGE"}, vstr2iface(keys)...)...)
 	return err
 }
 
-// http://redis.io/commands/rpush
-func (c *Client) RPush(key string, values ...string) (int, error) {
-	v, err := c.execWithKey(true, "RPUSH", key, vstr2iface(values)...)
+// NoExpiry is returned by PTTL for keys that have no timeout.
+const NoExpiry time.Duration = -1
+
+// http://redis.io/commands/pttl
+// PTTL is like TTL, with millisecond precision. It returns NoExpiry if key
+// has no timeout, or ErrNil if it does not exist.
+func (c *Client) PTTL(key string) (time.Duration, error) {
+	v, err := c.execWithKey(true, "PTTL", key)
+	if err != nil {
+		return 0, err
+	}
+	n, err := iface2int(v)
+	switch {
+	case err != nil:
+		return 0, err
+	case n == -2:
+		return 0, ErrNil
+	case n < 0:
+		return NoExpiry, nil
+	}
+	return time.Duration(n) * time.Millisecond, nil
+}
+
+// http://redis.io/commands/publish
+// Publish returns the number of clients that received the message.
+// On sharded connections, messages are published on the server selected
+// by the channel name, which is where PubSub subscribes to it.
+func (c *Client) Publish(channel string, value string) (int, error) {
+	v, err := c.execWithKey(true, "PUBLISH", channel, value)
+	if err != nil {
+		return 0, err
+	}
+	return iface2int(v)
+}
+
+// http://redis.io/commands/pubsub
+// PubSubChannels returns the active channels matching pattern on all
+// servers, or all active channels if pattern is empty.
+func (c *Client) PubSubChannels(pattern string) ([]string, error) {
+	return c.pubSubChannels("CHANNELS", pattern)
+}
+
+// http://redis.io/commands/pubsub
+// PubSubShardChannels works like PubSubChannels for sharded channels.
+func (c *Client) PubSubShardChannels(pattern string) ([]string, error) {
+	return c.pubSubChannels("SHARDCHANNELS", pattern)
+}
+
+func (c *Client) pubSubChannels(subcmd, pattern string) ([]string, error) {
+	a := []interface{}{"PUBSUB", subcmd}
+	if pattern != "" {
+		a = append(a, pattern)
+	}
+	r, err := c.execOnInstances(true, a...)
+	if err != nil {
+		return nil, err
+	}
+	channels := []string{}
+	seen := make(map[string]bool)
+	for _, v := range r {
+		for _, ch := range iface2vstr(v) {
+			if !seen[ch] {
+				seen[ch] = true
+				channels = append(channels, ch)
+			}
+		}
+	}
+	return channels, nil
+}
+
+// http://redis.io/commands/pubsub
+// PubSubNumSub returns the number of subscribers of each channel, on the
+// server selected by the channel name.
+func (c *Client) PubSubNumSub(channels ...string) (map[string]int, error) {
+	return c.pubSubNumSub("NUMSUB", channels)
+}
+
+// http://redis.io/commands/pubsub
+// PubSubShardNumSub works like PubSubNumSub for sharded channels.
+func (c *Client) PubSubShardNumSub(channels ...string) (map[string]int, error) {
+	return c.pubSubNumSub("SHARDNUMSUB", channels)
+}
+
+func (c *Client) pubSubNumSub(subcmd string, channels []string) (map[string]int, error) {
+	groups, err := c.groupKeys("PUBSUB", channels)
+	if err != nil {
+		return nil, err
+	}
+	var lk sync.Mutex
+	m := make(map[string]int)
+	err = c.execOnGroups(groups, func(g *keyGroup) error {
+		a := append([]interface{}{"PUBSUB", subcmd}, vstr2iface(g.keys)...)
+		v, err := c.execWithAddr(true, g.srv, a...)
+		if err != nil {
+			return err
+		}
+		items, ok := v.([]interface{})
+		if !ok || len(items) != len(g.keys)*2 {
+			return ErrServerError
+		}
+		lk.Lock()
+		defer lk.Unlock()
+		for n := 0; n < len(items); n += 2 {
+			ch, e1 := iface2str(items[n])
+			count, e2 := iface2int(items[n+1])
+			if e1 != nil || e2 != nil {
+				return ErrServerError
+			}
+			m[ch] = count
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// http://redis.io/commands/pubsub
+// PubSubNumPat returns the number of pattern subscriptions. PubSub
+// subscribes patterns on every server, thus the highest count among the
+// servers is returned.
+func (c *Client) PubSubNumPat() (int, error) {
+	r, err := c.execOnInstances(true, "PUBSUB", "NUMPAT")
+	if err != nil {
+		return 0, err
+	}
+	max := 0
+	for _, v := range r {
+		n, err := iface2int(v)
+		if err != nil {
+			return 0, err
+		}
+		if n > max {
+			max = n
+		}
+	}
+	return max, nil
+}
+
+// http://redis.io/commands/spublish
+// SPublish publishes a message to a sharded channel, which requires redis
+// 7.0 or newer, and returns the number of clients that received it.
+func (c *Client) SPublish(channel string, value string) (int, error) {
+	v, err := c.execWithKey(true, "SPUBLISH", channel, value)
+	if err != nil {
+		return 0, err
+	}
+	return iface2int(v)
+}
+
+// http://redis.io/commands/rpush
+func (c *Client) RPush(key string, values ...string) (int, error) {
+	v, err := c.execWithKey(true, "RPUSH", key, vstr2iface(values)...)
+	if err != nil {
+		return 0, err
+	}
+	return iface2int(v)
+}
+
+// http://redis.io/commands/rpushx
+// RPushX pushes values to the tail of a list only if it exists, and
+// returns the length of the list.
+func (c *Client) RPushX(key string, values ...string) (int, error) {
+	v, err := c.execWithKey(true, "RPUSHX", key, vstr2iface(values)...)
+	if err != nil {
+		return 0, err
+	}
+	return iface2int(v)
+}
+
+// http://redis.io/commands/sadd
+func (c *Client) SAdd(key string, vs ...interface{}) (int, error) {
+	v, err := c.execWithKey(true, "SADD", key, vs...)
+
+	if err != nil {
+		return 0, err
+	}
+	return iface2int(v)
+}
+
+// http://redis.io/commands/scard
+func (c *Client) SCard(key string) (int, error) {
+	v, err := c.execWithKey(true, "SCARD", key)
+
+	if err != nil {
+		return 0, err
+	}
+	return iface2int(v)
+}
+
+// setop executes SINTER, SUNION or SDIFF. On sharded connections, the
+// command is executed on each server holding any of the keys, and the
+// results are combined by the client, so it's not atomic.
+func (c *Client) setop(cmd string, keys []string) ([]string, error) {
+	groups, err := c.groupKeys(cmd, keys)
+	if err != nil {
+		return nil, err
+	}
+	if len(groups) < 2 {
+		srv, err := c.pickServerForKeys(cmd, keys)
+		if err != nil {
+			return nil, err
+		}
+		a := append([]interface{}{cmd}, vstr2iface(keys)...)
+		v, err := c.execWithAddr(true, srv, a...)
+		if err != nil {
+			return nil, err
+		}
+		return iface2vstr(v), nil
+	}
+	pos := make(map[*keyGroup]int)
+	for n, g := range groups {
+		pos[g] = n
+	}
+	resp := make([][]string, len(groups))
+	err = c.execOnGroups(groups, func(g *keyGroup) error {
+		x := cmd
+		if cmd == "SDIFF" && pos[g] > 0 {
+			// Members of the first key are removed if they're in
+			// any of the other keys.
+			x = "SUNION"
+		}
+		a := append([]interface{}{x}, vstr2iface(g.keys)...)
+		v, err := c.execWithAddr(true, g.srv, a...)
+		if err != nil {
+			return err
+		}
+		resp[pos[g]] = iface2vstr(v)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	// Members are counted once per server, since each result is a set.
+	count := make(map[string]int)
+	for _, members := range resp {
+		for _, m := range members {
+			count[m]++
+		}
+	}
+	r := []string{}
+	switch cmd {
+	case "SINTER":
+		for _, m := range resp[0] {
+			if count[m] == len(resp) {
+				r = append(r, m)
+			}
+		}
+	case "SDIFF":
+		for _, m := range resp[0] {
+			if count[m] == 1 {
+				r = append(r, m)
+			}
+		}
+	default:
+		for m := range count {
+			r = append(r, m)
+		}
+	}
+	return r, nil
+}
+
+// setstore executes SINTERSTORE, SUNIONSTORE or SDIFFSTORE.
+func (c *Client) setstore(cmd, dst string, keys []string) (int, error) {
+	keys = append([]string{dst}, keys...)
+	srv, err := c.pickServerForKeys(cmd, keys)
+	if err != nil {
+		return 0, err
+	}
+	v, err := c.execWithAddr(true, srv, append([]interface{}{cmd}, vstr2iface(keys)...)...)
+	if err != nil {
+		return 0, err
+	}
+	return iface2int(v)
+}
+
+// http://redis.io/commands/sdiff
+// SDiff returns the members of the first set that are not in any of the
+// other sets. See SInter for sharded connections.
+func (c *Client) SDiff(keys ...string) ([]string, error) {
+	return c.setop("SDIFF", keys)
+}
+
+// http://redis.io/commands/sdiffstore
+// SDiffStore is like SDiff, but stores the result in dst and returns its
+// size. All keys must be on the same server.
+func (c *Client) SDiffStore(dst string, keys ...string) (int, error) {
+	return c.setstore("SDIFFSTORE", dst, keys)
+}
+
+// http://redis.io/commands/sinter
+// SInter returns the members of the intersection of all sets. On sharded
+// connections, one SINTER command is issued per server concurrently and
+// the results are intersected by the client, so it's not atomic.
+func (c *Client) SInter(keys ...string) ([]string, error) {
+	return c.setop("SINTER", keys)
+}
+
+// http://redis.io/commands/sintercard
+// SInterCard returns the size of the intersection of all sets, counting up
+// to limit members if limit is not zero. All keys must be on the same
+// server. It requires redis 7.0 or newer.
+func (c *Client) SInterCard(limit int, keys ...string) (int, error) {
+	srv, err := c.pickServerForKeys("SINTERCARD", keys)
+	if err != nil {
+		return 0, err
+	}
+	a := append([]interface{}{"SINTERCARD", len(keys)}, vstr2iface(keys)...)
+	if limit > 0 {
+		a = append(a, "LIMIT", limit)
+	}
+	v, err := c.execWithAddr(true, srv, a...)
+	if err != nil {
+		return 0, err
+	}
+	return iface2int(v)
+}
+
+// http://redis.io/commands/sinterstore
+// SInterStore is like SInter, but stores the result in dst and returns its
+// size. All keys must be on the same server.
+func (c *Client) SInterStore(dst string, keys ...string) (int, error) {
+	return c.setstore("SINTERSTORE", dst, keys)
+}
+
+// http://redis.io/commands/sismember
+func (c *Client) SIsMember(key, member string) (bool, error) {
+	v, err := c.execWithKey(true, "SISMEMBER", key, member)
+	if err != nil {
+		return false, err
+	}
+	return iface2bool(v)
+}
+
+// http://redis.io/commands/script-exists
+// ScriptExists checks whether each script is loaded, in the same order
+// they're given. Scripts are only reported as loaded if they're loaded on
+// every server.
+func (c *Client) ScriptExists(sha1 ...string) ([]bool, error) {
+	a := append([]interface{}{"SCRIPT", "EXISTS"}, vstr2iface(sha1)...)
+	r, err := c.execOnInstances(true, a...)
+	if err != nil {
+		return nil, err
+	}
+	exists := make([]bool, len(sha1))
+	for n := range exists {
+		exists[n] = true
+	}
+	for _, v := range r {
+		items, err := iface2vint(v)
+		if err != nil {
+			return nil, err
+		} else if len(items) != len(sha1) {
+			return nil, ErrServerError
+		}
+		for n, item := range items {
+			exists[n] = exists[n] && item == 1
+		}
+	}
+	return exists, nil
+}
+
+// http://redis.io/commands/script-flush
+// ScriptFlush removes all scripts from the cache of every server.
+func (c *Client) ScriptFlush() error {
+	_, err := c.execOnInstances(true, "SCRIPT", "FLUSH")
+	return err
+}
+
+// http://redis.io/commands/script-kill
+// ScriptKill kills the scripts running on every server. The NOTBUSY errors
+// of servers not running any script are ignored, unless no server was
+// running one, in which case the NOTBUSY error is returned.
+func (c *Client) ScriptKill() error {
+	r, err := c.execOnInstances(true, "SCRIPT", "KILL")
+	return ignoreNotBusy(r, err)
+}
+
+// ignoreNotBusy removes the NOTBUSY errors from the ServerErrors of a
+// command run on every server, and returns one of them only if no server
+// succeeded.
+func ignoreNotBusy(r map[string]interface{}, err error) error {
+	errs, ok := err.(ServerErrors)
+	if !ok {
+		return err
+	}
+	var notBusy error
+	for addr, e := range errs {
+		if e, ok := e.(Error); ok && strings.HasPrefix(string(e), "NOTBUSY") {
+			notBusy = e
+			delete(errs, addr)
+		}
+	}
+	switch {
+	case len(errs) > 0:
+		return errs
+	case len(r) == 0:
+		return notBusy
+	}
+	return nil
+}
+
+// http://redis.io/commands/script-load
+// ScriptLoad loads a script on every server, since EvalSha may run it on
+// any of them, and returns its SHA1 digest.
+func (c *Client) ScriptLoad(script string) (string, error) {
+	r, err := c.execOnInstances(true, "SCRIPT", "LOAD", script)
+	if err != nil {
+		return "", err
+	}
+	for _, v := range r {
+		return iface2str(v)
+	}
+	return "", ErrServerError
+}
+
+// SetArgs are the options of SET. At most one of EX, PX, EXAT, PXAT and
+// KeepTTL can be set, and NX and XX are mutually exclusive.
+type SetArgs struct {
+	EX      int  // expire in seconds
+	PX      int  // expire in milliseconds
+	EXAT    int  // expire at a unix time, in seconds
+	PXAT    int  // expire at a unix time, in milliseconds
+	NX      bool // only set the key if it does not exist
+	XX      bool // only set the key if it exists
+	KeepTTL bool // keep the timeout of the key
+	Get     bool // return the old value
+}
+
+// args returns the options in the form of command arguments.
+func (o SetArgs) args() []interface{} {
+	a := expiry(o.EX, o.PX, o.EXAT, o.PXAT)
+	if o.NX {
+		a = append(a, "NX")
+	}
+	if o.XX {
+		a = append(a, "XX")
+	}
+	if o.KeepTTL {
+		a = append(a, "KEEPTTL")
+	}
+	if o.Get {
+		a = append(a, "GET")
+	}
+	return a
+}
+
+// http://redis.io/commands/set
+// Set sets the value of a key, with the options in args. It returns true
+// if the value was set, which is false if the NX or XX condition was not
+// met. If Get is set, it also returns the old value, which is nil if the
+// key did not exist. Get with NX requires redis 7.0 or newer.
+func (c *Client) Set(key, value string, args SetArgs) (bool, *string, error) {
+	v, err := c.execWithKey(true, "SET", key, append([]interface{}{value}, args.args()...)...)
+	if err != nil {
+		return false, nil, err
+	}
+	if !args.Get {
+		return v != nil, nil, nil
+	}
+	var old *string
+	if v != nil {
+		s, err := iface2str(v)
+		if err != nil {
+			return false, nil, err
+		}
+		old = &s
+	}
+	// With GET, the reply is the old value rather than whether the
+	// value was set, which is only unknown with NX or XX.
+	switch {
+	case args.NX:
+		return v == nil, old, nil
+	case args.XX:
+		return v != nil, old, nil
+	}
+	return true, old, nil
+}
+
+// SetWithExNx sets a key that expires in ex seconds, only if it does
+// not exist.
+//
+// Deprecated: use Set with SetArgs{EX: ex, NX: true}, which reports
+// whether the key was set.
+func (c *Client) SetWithExNx(key, value string, ex int) (err error) {
+	_, _, err = c.Set(key, value, SetArgs{EX: ex, NX: true})
+	return
+}
+
+// SetWithNx sets a key only if it does not exist.
+//
+// Deprecated: use SetNX, which reports whether the key was set.
+func (c *Client) SetWithNx(key, value string) (err error) {
+	_, _, err = c.Set(key, value, SetArgs{NX: true})
+	return
+}
+
+// http://redis.io/commands/setbit
+func (c *Client) SetBit(key string, offset, value int) (int, error) {
+	v, err := c.execWithKey(true, "SETBIT", key, offset, value)
+	if err != nil {
+		return 0, err
+	}
+	return iface2int(v)
+}
+
+// http://redis.io/commands/setex
+// SetEx sets a key that expires in the given number of seconds.
+//
+// Deprecated: use Set with SetArgs{EX: seconds}.
+func (c *Client) SetEx(key string, seconds int, value string) (err error) {
+	_, _, err = c.Set(key, value, SetArgs{EX: seconds})
+	return
+}
+
+// http://redis.io/commands/psetex
+// PSetEx is like SetEx, with the timeout in milliseconds. It returns true
+// if the value was set.
+func (c *Client) PSetEx(key string, milliseconds int, value string) (bool, error) {
+	v, err := c.execWithKey(true, "PSETEX", key, milliseconds, value)
+	if err != nil {
+		return false, err
+	}
+	return v == "OK", nil
+}
+
+// http://redis.io/commands/setnx
+// SetNX sets a key only if it does not exist, and returns true if it was
+// set.
+func (c *Client) SetNX(key, value string) (bool, error) {
+	v, err := c.execWithKey(true, "SETNX", key, value)
+	if err != nil {
+		return false, err
+	}
+	return iface2bool(v)
+}
+
+// http://redis.io/commands/setrange
+// SetRange overwrites part of the value of a key starting at offset, and
+// returns the length of the new value.
+func (c *Client) SetRange(key string, offset int, value string) (int, error) {
+	v, err := c.execWithKey(true, "SETRANGE", key, offset, value)
+	if err != nil {
+		return 0, err
+	}
+	return iface2int(v)
+}
+
+// http://redis.io/commands/strlen
+// StrLen returns the length of the value of a key, or 0 if it does not
+// exist.
+func (c *Client) StrLen(key string) (int, error) {
+	v, err := c.execWithKey(true, "STRLEN", key)
+	if err != nil {
+		return 0, err
+	}
+	return iface2int(v)
+}
+
+// http://redis.io/commands/smembers
+func (c *Client) SMembers(key string) ([]string, error) {
+
+	var v interface{}
+	var err error
+
+	v, err = c.execWithKey(true, "SMEMBERS", key)
+
+	if err != nil {
+		return []string{}, err
+	}
+	return iface2vstr(v), nil
+}
+
+// http://redis.io/commands/smismember
+// SMIsMember checks whether each member is in the set, in the same order
+// they're given. It requires redis 6.2 or newer.
+func (c *Client) SMIsMember(key string, members ...string) ([]bool, error) {
+	v, err := c.execWithKey(true, "SMISMEMBER", key, vstr2iface(members)...)
+	if err != nil {
+		return nil, err
+	}
+	items, err := iface2vint(v)
+	if err != nil {
+		return nil, err
+	}
+	r := make([]bool, len(items))
+	for n, item := range items {
+		r[n] = item == 1
+	}
+	return r, nil
+}
+
+// http://redis.io/commands/spop
+// SPop removes and returns a random member of a set. If the set does not
+// exist, it returns an empty string.
+func (c *Client) SPop(key string) (string, error) {
+	v, err := c.execWithKey(true, "SPOP", key)
+	if err != nil {
+		return "", err
+	}
+	return iface2str(v)
+}
+
+// SPopCount removes and returns up to count random members of a set.
+func (c *Client) SPopCount(key string, count int) ([]string, error) {
+	v, err := c.execWithKey(true, "SPOP", key, count)
+	if err != nil {
+		return nil, err
+	}
+	return iface2vstr(v), nil
+}
+
+// http://redis.io/commands/srandmember
+func (c *Client) SRandMember(key string) (string, error) {
+	v, err := c.execWithKey(true, "SRANDMEMBER", key)
+
+	if err != nil {
+		return "", err
+	}
+	return iface2str(v)
+}
+
+// SRandMemberCount returns up to count random members of a set, or fewer
+// if the set is smaller. A negative count allows the same member to be
+// returned more than once.
+func (c *Client) SRandMemberCount(key string, count int) ([]string, error) {
+	v, err := c.execWithKey(true, "SRANDMEMBER", key, count)
+	if err != nil {
+		return nil, err
+	}
+	return iface2vstr(v), nil
+}
+
+// http://redis.io/commands/sunion
+// SUnion returns the members of the union of all sets. See SInter for
+// sharded connections.
+func (c *Client) SUnion(keys ...string) ([]string, error) {
+	return c.setop("SUNION", keys)
+}
+
+// http://redis.io/commands/sunionstore
+// SUnionStore is like SUnion, but stores the result in dst and returns its
+// size. All keys must be on the same server.
+func (c *Client) SUnionStore(dst string, keys ...string) (int, error) {
+	return c.setstore("SUNIONSTORE", dst, keys)
+}
+
+// PubSubMessage is a message received by Subscribe.
+type PubSubMessage struct {
+	Error   error
+	Value   string
+	Channel string
+}
+
+// http://redis.io/commands/subscribe
+// Subscribe subscribes to a single channel and sends its messages to ch,
+// until stop is signaled or the connection fails. The last message sent
+// to ch carries the error that stopped the subscription.
+// See NewPubSub for multiple channels and patterns.
+func (c *Client) Subscribe(channel string, ch chan PubSubMessage, stop chan bool) error {
+	ps := c.NewPubSub()
+	if err := ps.Subscribe(channel); err != nil {
+		ps.Close()
+		return err
+	}
+	// Wait for the confirmation, so messages published after
+	// Subscribe returns are not lost.
+	if _, err := ps.Receive(); err != nil {
+		ps.Close()
+		return err
+	}
+	done := make(chan bool)
+	go func() {
+		select {
+		case <-stop:
+			ps.Close()
+		case <-done:
+		}
+	}()
+	go func() {
+		defer close(done)
+		for {
+			ev, err := ps.Receive()
+			if err != nil {
+				ps.Close()
+				ch <- PubSubMessage{Error: err}
+				return
+			}
+			if m, ok := ev.(Message); ok {
+				ch <- PubSubMessage{Value: m.Data, Channel: m.Channel}
+			}
+		}
+	}()
+	return nil
+}
+
+// http://redis.io/commands/touch
+// Touch returns the number of keys that were touched, issuing one TOUCH
+// command per server concurrently on sharded connections.
+func (c *Client) Touch(keys ...string) (int, error) {
+	return c.execSumWithKeys("TOUCH", keys)
+}
+
+// http://redis.io/commands/ttl
+func (c *Client) TTL(key string) (int, error) {
+	v, err := c.execWithKey(true, "TTL", key)
+	if err != nil {
+		return 0, err
+	}
+	return iface2int(v)
+}
+
+// http://redis.io/commands/type
+// Type returns the type of the value of key, e.g. "string" or "hash", or
+// "none" if it does not exist.
+func (c *Client) Type(key string) (string, error) {
+	v, err := c.execWithKey(true, "TYPE", key)
+	if err != nil {
+		return "", err
+	}
+	return iface2str(v)
+}
+
+// http://redis.io/commands/unlink
+// Unlink returns the number of keys that were unlinked, issuing one UNLINK
+// command per server concurrently on sharded connections.
+func (c *Client) Unlink(keys ...string) (int, error) {
+	return c.execSumWithKeys("UNLINK", keys)
+}
+
+// Z is a member of a sorted set, and its score.
+type Z struct {
+	Member string
+	Score  float64
+}
+
+// zargs returns members as command arguments, score first.
+func zargs(members []Z) []interface{} {
+	a := make([]interface{}, 0, len(members)*2)
+	for _, z := range members {
+		a = append(a, z.Score, z.Member)
+	}
+	return a
+}
+
+// ZAddArgs are the options of ZADD.
+type ZAddArgs struct {
+	NX bool // only add new members
+	XX bool // only update existing members
+	GT bool // only update scores if the new score is greater
+	LT bool // only update scores if the new score is less
+	CH bool // count changed members, not only added ones
+}
+
+// args returns the options in the form of command arguments.
+func (o ZAddArgs) args() []interface{} {
+	var a []interface{}
+	if o.NX {
+		a = append(a, "NX")
+	}
+	if o.XX {
+		a = append(a, "XX")
+	}
+	if o.GT {
+		a = append(a, "GT")
+	}
+	if o.LT {
+		a = append(a, "LT")
+	}
+	if o.CH {
+		a = append(a, "CH")
+	}
+	return a
+}
+
+// http://redis.io/commands/zadd
+// ZAdd adds members to a sorted set, or updates their scores, and returns
+// the number of members added.
+func (c *Client) ZAdd(key string, members ...Z) (int, error) {
+	return c.ZAddArgs(key, ZAddArgs{}, members...)
+}
+
+// http://redis.io/commands/zadd
+// ZAddArgs is like ZAdd, with options. GT, LT and NX are mutually
+// exclusive, and GT and LT require redis 6.2 or newer.
+func (c *Client) ZAddArgs(key string, args ZAddArgs, members ...Z) (int, error) {
+	a := append(args.args(), zargs(members)...)
+	v, err := c.execWithKey(true, "ZADD", key, a...)
+	if err != nil {
+		return 0, err
+	}
+	return iface2int(v)
+}
+
+// http://redis.io/commands/zadd
+// ZAddIncr increments the score of a member like ZIncrBy, with the options
+// of ZADD, and returns the new score. It returns ErrNil if the options
+// prevented the increment. CH is ignored.
+func (c *Client) ZAddIncr(key string, args ZAddArgs, member Z) (float64, error) {
+	args.CH = false
+	a := append(args.args(), "INCR", member.Score, member.Member)
+	v, err := c.execWithKey(true, "ZADD", key, a...)
 	if err != nil {
 		return 0, err
 	}
-	return iface2int(v)
+	return iface2score(v)
 }
 
-// http://redis.io/commands/sadd
-func (c *Client) SAdd(key string, vs ...interface{}) (int, error) {
-	v, err := c.execWithKey(true, "SADD", key, vs...)
-
+// http://redis.io/commands/zcard
+func (c *Client) ZCard(key string) (int, error) {
+	v, err := c.execWithKey(true, "ZCARD", key)
 	if err != nil {
 		return 0, err
 	}
 	return iface2int(v)
 }
 
-// http://redis.io/commands/scard
-func (c *Client) SCard(key string) (int, error) {
-	v, err := c.execWithKey(true, "SCARD", key)
-
+// http://redis.io/commands/zcount
+// ZCount returns the number of members with scores between min and max,
+// inclusive. Bounds are strings, as in redis: a number, "-inf" or "+inf",
+// and prefixed by "(" to be exclusive, e.g. ZCount("k", "(1", "+inf").
+func (c *Client) ZCount(key, min, max string) (int, error) {
+	v, err := c.execWithKey(true, "ZCOUNT", key, min, max)
 	if err != nil {
 		return 0, err
 	}
 	return iface2int(v)
 }
 
-// http://redis.io/commands/script-load
-func (c *Client) ScriptLoad(script string) (string, error) {
-	v, err := c.execOnFirst(true, "SCRIPT", "LOAD", script)
+// http://redis.io/commands/zincrby
+// ZIncrBy increments the score of a member, and returns the new score.
+func (c *Client) ZIncrBy(key string, increment float64, member string) (float64, error) {
+	v, err := c.execWithKey(true, "ZINCRBY", key, increment, member)
 	if err != nil {
-		return "", err
+		return 0, err
 	}
-	return iface2str(v)
-}
-
-// http://redis.io/commands/set
-func (c *Client) Set(key, value string) (err error) {
-	_, err = c.execWithKey(true, "SET", key, value)
-	return
+	return iface2score(v)
 }
 
-// http://redis.io/commands/set with ex and nx
-func (c *Client) SetWithExNx(key, value string, ex int) (err error) {
-	_, err = c.execWithKey(true, "SET", key, value,"EX",ex,"NX")
-	return
-}
+// ZStore are the arguments of ZUNIONSTORE and ZINTERSTORE.
+type ZStore struct {
+	Keys []string
 
+	// Weights multiply the scores of each key. If empty, all weights
+	// are 1.
+	Weights []float64
 
-// http://redis.io/commands/set with nx
-func (c *Client) SetWithNx(key, value string) (err error) {
-	_, err = c.execWithKey(true, "SET", key, value,"NX")
-	return
+	// Aggregate is how scores of the same member are combined: "SUM",
+	// "MIN" or "MAX". If empty, SUM is used.
+	Aggregate string
 }
 
-
-// http://redis.io/commands/setbit
-func (c *Client) SetBit(key string, offset, value int) (int, error) {
-	v, err := c.execWithKey(true, "SETBIT", key, offset, value)
+// zstore executes ZUNIONSTORE or ZINTERSTORE. All keys must be on the
+// same server.
+func (c *Client) zstore(cmd, dest string, store ZStore) (int, error) {
+	srv, err := c.pickServerForKeys(cmd, append([]string{dest}, store.Keys...))
+	if err != nil {
+		return 0, err
+	}
+	a := []interface{}{cmd, dest, len(store.Keys)}
+	a = append(a, vstr2iface(store.Keys)...)
+	if len(store.Weights) > 0 {
+		a = append(a, "WEIGHTS")
+		for _, w := range store.Weights {
+			a = append(a, w)
+		}
+	}
+	if store.Aggregate != "" {
+		a = append(a, "AGGREGATE", store.Aggregate)
+	}
+	v, err := c.execWithAddr(true, srv, a...)
 	if err != nil {
 		return 0, err
 	}
 	return iface2int(v)
 }
 
-// http://redis.io/commands/setex
-func (c *Client) SetEx(key string, seconds int, value string) (err error) {
-	_, err = c.execWithKey(true, "SETEX", key, seconds, value)
-	return
+// http://redis.io/commands/zinterstore
+// ZInterStore stores the intersection of sorted sets in dest, and returns
+// the number of members of dest. Dest and all keys must be on the same
+// server.
+func (c *Client) ZInterStore(dest string, store ZStore) (int, error) {
+	return c.zstore("ZINTERSTORE", dest, store)
 }
 
-// http://redis.io/commands/smembers
-func (c *Client) SMembers(key string) ([]string, error) {
-
-	var v interface{}
-	var err error
-
-	v, err = c.execWithKey(true, "SMEMBERS", key)
-
+// http://redis.io/commands/zmscore
+// ZMScore returns the scores of the given members, in the same order.
+// Scores of members that do not exist are nil. It requires redis 6.2 or
+// newer.
+func (c *Client) ZMScore(key string, members ...string) ([]*float64, error) {
+	v, err := c.execWithKey(true, "ZMSCORE", key, vstr2iface(members)...)
 	if err != nil {
-		return []string{}, err
+		return nil, err
 	}
-	return iface2vstr(v), nil
+	items, ok := v.([]interface{})
+	if !ok {
+		return nil, ErrServerError
+	}
+	r := make([]*float64, len(items))
+	for n, item := range items {
+		score, err := iface2score(item)
+		if err == ErrNil {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		r[n] = &score
+	}
+	return r, nil
 }
 
-// http://redis.io/commands/srandmember
-func (c *Client) SRandMember(key string) (string, error) {
-	v, err := c.execWithKey(true, "SRANDMEMBER", key)
-
+// zpop executes ZPOPMIN or ZPOPMAX.
+func (c *Client) zpop(cmd, key string, count int) ([]Z, error) {
+	var a []interface{}
+	if count > 0 {
+		a = append(a, count)
+	}
+	v, err := c.execWithKey(true, cmd, key, a...)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
-	return iface2str(v)
+	return iface2zslice(v)
 }
 
-type PubSubMessage struct {
-	Error   error
-	Value   string
-	Channel string
+// http://redis.io/commands/zpopmax
+// ZPopMax removes and returns up to count members with the highest scores.
+// If count is zero, one member is removed.
+func (c *Client) ZPopMax(key string, count int) ([]Z, error) {
+	return c.zpop("ZPOPMAX", key, count)
 }
 
-// http://redis.io/commands/subscribe
-func (c *Client) Subscribe(channel string, ch chan PubSubMessage, stop chan bool) error {
-	srv, err := c.selector.PickServer("")
+// http://redis.io/commands/zpopmin
+// ZPopMin removes and returns up to count members with the lowest scores.
+// If count is zero, one member is removed.
+func (c *Client) ZPopMin(key string, count int) ([]Z, error) {
+	return c.zpop("ZPOPMIN", key, count)
+}
 
-	if err != nil {
-		return err
+// zrange executes one of the ZRANGE family of commands, with a and
+// optionally WITHSCORES.
+func (c *Client) zrange(cmd, key string, withscores bool, a ...interface{}) (interface{}, error) {
+	if withscores {
+		a = append(a, "WITHSCORES")
 	}
+	return c.execWithKey(true, cmd, key, a...)
+}
 
-	cn, err := c.getConn(srv)
-	if err != nil {
-		return err
+// limit returns the LIMIT arguments of the ZRANGEBY family of commands.
+// A count of zero or less returns all members after offset.
+func limit(offset, count int) []interface{} {
+	if offset <= 0 && count <= 0 {
+		return nil
 	}
+	if count <= 0 {
+		count = -1
+	}
+	return []interface{}{"LIMIT", offset, count}
+}
 
-	_, err = c.execute(cn.rw, "SUBSCRIBE", channel)
-
+// http://redis.io/commands/zrange
+// ZRange returns the members between the ranks start and stop, inclusive,
+// ordered from the lowest to the highest score. Negative ranks count from
+// the end, e.g. -1 is the last member.
+func (c *Client) ZRange(key string, start, stop int) ([]string, error) {
+	v, err := c.zrange("ZRANGE", key, false, start, stop)
 	if err != nil {
-		cn.condRelease(&err)
-		return err
+		return nil, err
 	}
+	return iface2vstr(v), nil
+}
 
-	if err = cn.nc.SetDeadline(time.Time{}); err != nil {
-		cn.condRelease(&err)
-		return err
+// http://redis.io/commands/zrange
+// ZRangeWithScores is like ZRange, and returns members with their scores.
+func (c *Client) ZRangeWithScores(key string, start, stop int) ([]Z, error) {
+	v, err := c.zrange("ZRANGE", key, true, start, stop)
+	if err != nil {
+		return nil, err
 	}
+	return iface2zslice(v)
+}
 
-	sibStop := make(chan bool)
-	go func() {
-		for {
-			select {
-			case <-stop:
-				cn.nc.Close()
-			case <-sibStop:
-				return
-			}
-		}
-	}()
-
-	go func() {
-		for {
-			raw, err := c.parseResponse(cn.rw.Reader)
-			if err != nil {
-				msg := PubSubMessage{
-					Error: err,
-				}
-				ch <- msg
-
-				sibStop <- true
-				cn.nc.Close()
-				return
-			}
-
-			switch raw.(type) {
-			case []interface{}:
-				ret := raw.([]interface{})
-				msg := PubSubMessage{
-					Value:   ret[2].(string),
-					Channel: ret[1].(string),
-					Error:   nil,
-				}
-				ch <- msg
-			default:
-				msg := PubSubMessage{
-					Error: errors.New("Protocol Error"),
-				}
-				ch <- msg
-				sibStop <- true
-				cn.nc.Close()
-				return
-			}
-		}
-	}()
-
-	return err
+// http://redis.io/commands/zrangebylex
+// ZRangeByLex returns the members between min and max, when all members
+// have the same score. Bounds are prefixed by "[" to be inclusive or "("
+// to be exclusive, or are "-" and "+" for no bound. Offset and count
+// limit the result as LIMIT does; a count of zero returns all members.
+func (c *Client) ZRangeByLex(key, min, max string, offset, count int) ([]string, error) {
+	a := append([]interface{}{min, max}, limit(offset, count)...)
+	v, err := c.zrange("ZRANGEBYLEX", key, false, a...)
+	if err != nil {
+		return nil, err
+	}
+	return iface2vstr(v), nil
 }
 
-// http://redis.io/commands/ttl
-func (c *Client) TTL(key string) (int, error) {
-	v, err := c.execWithKey(true, "TTL", key)
+// http://redis.io/commands/zrangebyscore
+// ZRangeByScore returns the members with scores between min and max,
+// ordered from the lowest to the highest score. Bounds are like in ZCount,
+// and offset and count are like in ZRangeByLex.
+func (c *Client) ZRangeByScore(key, min, max string, offset, count int) ([]string, error) {
+	a := append([]interface{}{min, max}, limit(offset, count)...)
+	v, err := c.zrange("ZRANGEBYSCORE", key, false, a...)
 	if err != nil {
-		return 0, err
+		return nil, err
 	}
-	return iface2int(v)
+	return iface2vstr(v), nil
 }
 
-func (c *Client) ZAdd(key string, vs ...interface{}) (int, error) {
-	if len(vs)%2 != 0 {
-		return 0, errors.New("Incomplete parameter sequence")
+// http://redis.io/commands/zrangebyscore
+// ZRangeByScoreWithScores is like ZRangeByScore, and returns members with
+// their scores.
+func (c *Client) ZRangeByScoreWithScores(key, min, max string, offset, count int) ([]Z, error) {
+	a := append([]interface{}{min, max}, limit(offset, count)...)
+	v, err := c.zrange("ZRANGEBYSCORE", key, true, a...)
+	if err != nil {
+		return nil, err
 	}
+	return iface2zslice(v)
+}
 
-	v, err := c.execWithKey(true, "ZADD", key, vs...)
+// http://redis.io/commands/zrank
+func (c *Client) ZRank(key string, member string) (string, error) {
+	v, err := c.execWithKey(true, "ZRANK", key, member)
+	if err != nil {
+		return "", err
+	}
+	return iface2str(v)
+}
 
+// http://redis.io/commands/zrem
+// ZRem removes members from a sorted set, and returns the number of
+// members removed.
+func (c *Client) ZRem(key string, members ...string) (int, error) {
+	v, err := c.execWithKey(true, "ZREM", key, vstr2iface(members)...)
 	if err != nil {
 		return 0, err
 	}
 	return iface2int(v)
 }
 
-func (c *Client) ZCard(key string) (int, error) {
-	v, err := c.execWithKey(true, "ZCARD", key)
-
+// http://redis.io/commands/zremrangebyrank
+// ZRemRangeByRank removes the members between the ranks start and stop,
+// like ZRange, and returns the number of members removed.
+func (c *Client) ZRemRangeByRank(key string, start, stop int) (int, error) {
+	v, err := c.execWithKey(true, "ZREMRANGEBYRANK", key, start, stop)
 	if err != nil {
 		return 0, err
 	}
 	return iface2int(v)
 }
 
-func (c *Client) ZCount(key string, min int, max int) (int, error) {
-	v, err := c.execWithKey(true, "ZCOUNT", key, min, max)
-
+// http://redis.io/commands/zremrangebyscore
+// ZRemRangeByScore removes the members with scores between min and max,
+// like ZCount, and returns the number of members removed.
+func (c *Client) ZRemRangeByScore(key, min, max string) (int, error) {
+	v, err := c.execWithKey(true, "ZREMRANGEBYSCORE", key, min, max)
 	if err != nil {
 		return 0, err
 	}
 	return iface2int(v)
 }
 
-// http://redis.io/commands/zrange
-func (c *Client) ZRange(key string, start int, stop int, withscores bool) ([]string, error) {
-	var v interface{}
-	var err error
-
-	if withscores == true {
-		v, err = c.execWithKey(true, "ZRANGE", key, start, stop, "WITHSCORES")
-	} else {
-		v, err = c.execWithKey(true, "ZRANGE", key, start, stop)
-	}
-
+// http://redis.io/commands/zrevrange
+// ZRevRange is like ZRange, ordered from the highest to the lowest score.
+func (c *Client) ZRevRange(key string, start, stop int) ([]string, error) {
+	v, err := c.zrange("ZREVRANGE", key, false, start, stop)
 	if err != nil {
-		return []string{}, err
+		return nil, err
 	}
 	return iface2vstr(v), nil
 }
 
 // http://redis.io/commands/zrevrange
-func (c *Client) ZRevRange(key string, start int, stop int, withscores bool) ([]string, error) {
-	var v interface{}
-	var err error
-
-	if withscores == true {
-		v, err = c.execWithKey(true, "ZREVRANGE", key, start, stop, "WITHSCORES")
-	} else {
-		v, err = c.execWithKey(true, "ZREVRANGE", key, start, stop)
+// ZRevRangeWithScores is like ZRevRange, and returns members with their
+// scores.
+func (c *Client) ZRevRangeWithScores(key string, start, stop int) ([]Z, error) {
+	v, err := c.zrange("ZREVRANGE", key, true, start, stop)
+	if err != nil {
+		return nil, err
 	}
+	return iface2zslice(v)
+}
 
+// http://redis.io/commands/zrevrangebylex
+// ZRevRangeByLex is like ZRangeByLex, in reverse order. Note that max
+// comes first.
+func (c *Client) ZRevRangeByLex(key, max, min string, offset, count int) ([]string, error) {
+	a := append([]interface{}{max, min}, limit(offset, count)...)
+	v, err := c.zrange("ZREVRANGEBYLEX", key, false, a...)
 	if err != nil {
-		return []string{}, err
+		return nil, err
 	}
 	return iface2vstr(v), nil
 }
 
-// http://redis.io/commands/zrank
-func (c *Client) ZRank(key string, member string) (string, error) {
-	v, err := c.execWithKey(true, "ZRANK", key, member)
+// http://redis.io/commands/zrevrangebyscore
+// ZRevRangeByScore is like ZRangeByScore, ordered from the highest to the
+// lowest score. Note that max comes first.
+func (c *Client) ZRevRangeByScore(key, max, min string, offset, count int) ([]string, error) {
+	a := append([]interface{}{max, min}, limit(offset, count)...)
+	v, err := c.zrange("ZREVRANGEBYSCORE", key, false, a...)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
-	return iface2str(v)
+	return iface2vstr(v), nil
 }
 
+// http://redis.io/commands/zrevrangebyscore
+// ZRevRangeByScoreWithScores is like ZRevRangeByScore, and returns members
+// with their scores.
+func (c *Client) ZRevRangeByScoreWithScores(key, max, min string, offset, count int) ([]Z, error) {
+	a := append([]interface{}{max, min}, limit(offset, count)...)
+	v, err := c.zrange("ZREVRANGEBYSCORE", key, true, a...)
+	if err != nil {
+		return nil, err
+	}
+	return iface2zslice(v)
+}
 
 // http://redis.io/commands/zscore
-func (c *Client) ZScore(key string, member string) (string, error) {
+// ZScore returns the score of a member, or ErrNil if it does not exist.
+func (c *Client) ZScore(key string, member string) (float64, error) {
 	v, err := c.execWithKey(true, "ZSCORE", key, member)
 	if err != nil {
-		return "", err
+		return 0, err
 	}
-	return iface2str(v)
+	return iface2score(v)
 }
 
-func (c *Client) ZRem(key string, vs ...interface{}) (int, error) {
-	v, err := c.execWithKey(true, "ZREM", key, vs...)
+// http://redis.io/commands/zunionstore
+// ZUnionStore stores the union of sorted sets in dest, and returns the
+// number of members of dest. Dest and all keys must be on the same server.
+func (c *Client) ZUnionStore(dest string, store ZStore) (int, error) {
+	return c.zstore("ZUNIONSTORE", dest, store)
+}
 
+// scanKey executes one of SSCAN, HSCAN or ZSCAN, and returns the next
+// cursor and the items of the reply.
+func (c *Client) scanKey(cmd, key string, cursor uint64, match string, count int) (uint64, []interface{}, error) {
+	opts := ScanOptions{Match: match, Count: count}
+	v, err := c.execWithKey(true, cmd, key, append([]interface{}{cursor}, opts.args()...)...)
 	if err != nil {
-		return 0, err
+		return 0, nil, err
 	}
-	return iface2int(v)
+	return parseScanReply(v)
 }
 
-// http://redis.io/commands/zscan
-func (c *Client) ZScan(key string, cursorStart int, pattern string, count int) ([]string,error) {
-	var v interface{}
-	var err error
+// http://redis.io/commands/sscan
+// SScan returns the next cursor and a page of members of the set.
+// The iteration starts with cursor 0 and ends when the next cursor is 0.
+// An empty match returns all members, and count 0 uses the redis default.
+func (c *Client) SScan(key string, cursor uint64, match string, count int) (uint64, []string, error) {
+	next, items, err := c.scanKey("SSCAN", key, cursor, match, count)
+	if err != nil {
+		return 0, nil, err
+	}
+	return next, iface2vstr(items), nil
+}
 
-	if pattern == "" && count == 0 {
-		v, err = c.execWithKey(true, "ZSCAN", key, cursorStart)
-	} else if pattern != "" && count == 0 {
-		v, err = c.execWithKey(true, "ZSCAN", key, cursorStart,"MATCH " + pattern)
-	}   else if pattern != "" && count != 0 {
-		v, err = c.execWithKey(true, "ZSCAN", key, cursorStart,"MATCH " + pattern , "COUNT",count)
-	}   else if pattern == "" && count != 0 {
-		v, err = c.execWithKey(true, "ZSCAN", key, cursorStart,"COUNT",count)
+// SScanAll calls SScan until the whole set is iterated, and returns all
+// the members. Members may be returned more than once.
+func (c *Client) SScanAll(key, match string, count int) ([]string, error) {
+	var (
+		cursor uint64
+		r      []string
+	)
+	for {
+		next, members, err := c.SScan(key, cursor, match, count)
+		if err != nil {
+			return nil, err
+		}
+		r = append(r, members...)
+		if cursor = next; cursor == 0 {
+			return r, nil
+		}
 	}
+}
 
+// http://redis.io/commands/hscan
+// HScan returns the next cursor and a page of fields and values of the
+// hash. It works like SScan.
+func (c *Client) HScan(key string, cursor uint64, match string, count int) (uint64, map[string]string, error) {
+	next, items, err := c.scanKey("HSCAN", key, cursor, match, count)
 	if err != nil {
-		return []string{}, err
+		return 0, nil, err
 	}
-    
-    scanRes := iface2scanres(v)
-    
-    return scanRes,nil
+	return next, iface2strmap(items), nil
 }
 
+// HScanAll calls HScan until the whole hash is iterated, and returns all
+// fields and values.
+func (c *Client) HScanAll(key, match string, count int) (map[string]string, error) {
+	var cursor uint64
+	r := make(map[string]string)
+	for {
+		next, fields, err := c.HScan(key, cursor, match, count)
+		if err != nil {
+			return nil, err
+		}
+		for k, v := range fields {
+			r[k] = v
+		}
+		if cursor = next; cursor == 0 {
+			return r, nil
+		}
+	}
+}
 
-// http://redis.io/commands/sscan
-func (c *Client) SScan(key string, cursorStart int, pattern string, count int) ([]string,error) {
-	var v interface{}
-	var err error
+// http://redis.io/commands/zscan
+// ZScan returns the next cursor and a page of members of the sorted set,
+// with their scores. It works like SScan.
+func (c *Client) ZScan(key string, cursor uint64, match string, count int) (uint64, []Z, error) {
+	next, items, err := c.scanKey("ZSCAN", key, cursor, match, count)
+	if err != nil {
+		return 0, nil, err
+	}
+	members, err := iface2zslice(items)
+	if err != nil {
+		return 0, nil, err
+	}
+	return next, members, nil
+}
 
-	if pattern == "" && count == 0 {
-		v, err = c.execWithKey(true, "SSCAN", key, cursorStart)
-	} else if pattern != "" && count == 0 {
-		v, err = c.execWithKey(true, "SSCAN", key, cursorStart,"MATCH " + pattern)
-	}   else if pattern != "" && count != 0 {
-		v, err = c.execWithKey(true, "SSCAN", key, cursorStart,"MATCH " + pattern , "COUNT",count)
-	}   else if pattern == "" && count != 0 {
-		v, err = c.execWithKey(true, "SSCAN", key, cursorStart,"COUNT",count)
+// ZScanAll calls ZScan until the whole sorted set is iterated, and returns
+// all members with their scores. Members may be returned more than once.
+func (c *Client) ZScanAll(key, match string, count int) ([]Z, error) {
+	var (
+		cursor uint64
+		r      []Z
+	)
+	for {
+		next, members, err := c.ZScan(key, cursor, match, count)
+		if err != nil {
+			return nil, err
+		}
+		r = append(r, members...)
+		if cursor = next; cursor == 0 {
+			return r, nil
+		}
 	}
+}
 
+// http://redis.io/commands/srem
+// SRem removes members from a set, and returns the number of members
+// removed.
+func (c *Client) SRem(key string, members ...string) (int, error) {
+	v, err := c.execWithKey(true, "SREM", key, vstr2iface(members)...)
 	if err != nil {
-		return []string{}, err
+		return 0, err
 	}
-    
-    scanRes := iface2scanres(v)
-    
-    return scanRes,nil
+	return iface2int(v)
 }
 
-// http://redis.io/commands/srem
-func (c *Client) SRem(key, field string) (err error) {
-	_, err = c.execWithKey(true, "SREM", key, field)
-	return
+// http://redis.io/commands/randomkey
+// RandomKey returns a random key, or an empty string if there are no keys.
+// On sharded connections, the key is picked from a random server that has
+// any keys.
+func (c *Client) RandomKey() (string, error) {
+	servers, err := c.Servers()
+	if err != nil {
+		return "", err
+	}
+	for _, n := range rand.Perm(len(servers)) {
+		v, err := c.execWithAddr(true, servers[n], "RANDOMKEY")
+		if err != nil {
+			return "", err
+		} else if v != nil {
+			return iface2str(v)
+		}
+	}
+	return "", nil
 }
 
 // http://redis.io/commands/rename
+// Rename renames key1 to key2. Both keys must be on the same server.
 func (c *Client) Rename(key1, key2 string) (err error) {
-	_, err = c.execWithKey(true, "RENAME", key1, key2)
-	return
-}
-
-// http://redis.io/commands/smove
-func (c *Client) SMove(set1, set2, key string) (err error) {
-	_, err = c.execWithKey(true, "SMOVE", set1, set2,key)
+	srv, err := c.pickServerForKeys("RENAME", []string{key1, key2})
+	if err != nil {
+		return
+	}
+	_, err = c.execWithAddr(true, srv, "RENAME", key1, key2)
 	return
 }
 
-
-
-// GetMulti is a batch version of Get. The returned map from keys to
-// items may have fewer elements than the input slice, due to memcache
-// cache misses. Each key must be at most 250 bytes in length.
-// If no error is returned, the returned map will also be non-nil.
-/*
-func (c *Client) GetMulti(keys []string) (map[string]*Item, error) {
-	var lk sync.Mutex
-	m := make(map[string]*Item)
-	addItemToMap := func(it *Item) {
-		lk.Lock()
-		defer lk.Unlock()
-		m[it.Key] = it
+// http://redis.io/commands/renamenx
+// RenameNX renames key1 to key2 only if key2 does not exist, and returns
+// true if it was renamed. Both keys must be on the same server.
+func (c *Client) RenameNX(key1, key2 string) (bool, error) {
+	srv, err := c.pickServerForKeys("RENAMENX", []string{key1, key2})
+	if err != nil {
+		return false, err
 	}
-
-	keyMap := make(map[net.Addr][]string)
-	for _, key := range keys {
-		if !legalKey(key) {
-			return nil, ErrMalformedKey
-		}
-		addr, err := c.selector.PickServer(key)
-		if err != nil {
-			return nil, err
-		}
-		keyMap[addr] = append(keyMap[addr], key)
+	v, err := c.execWithAddr(true, srv, "RENAMENX", key1, key2)
+	if err != nil {
+		return false, err
 	}
+	return iface2bool(v)
+}
 
-	ch := make(chan error, buffered)
-	for addr, keys := range keyMap {
-		go func(addr net.Addr, keys []string) {
-			//ch <- c.getFromAddr(addr, keys, addItemToMap)
-		}(addr, keys)
+// http://redis.io/commands/restore
+// Restore creates key from a value serialized by Dump, replacing key if
+// replace is true. If ttl is zero, the key has no timeout.
+func (c *Client) Restore(key string, ttl time.Duration, value string, replace bool) error {
+	a := []interface{}{ms(ttl), value}
+	if replace {
+		a = append(a, "REPLACE")
 	}
+	_, err := c.execWithKey(true, "RESTORE", key, a...)
+	return err
+}
 
-	var err error
-	for _ = range keyMap {
-		if ge := <-ch; ge != nil {
-			err = ge
-		}
-	}
-	return m, err
+// http://redis.io/commands/smove
+func (c *Client) SMove(set1, set2, key string) (err error) {
+	_, err = c.execWithKey(true, "SMOVE", set1, set2,key)
+	return
 }
-*/
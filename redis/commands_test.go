@@ -15,7 +15,13 @@
 package redis
 
 import (
+	"bufio"
+	"io"
+	"io/ioutil"
 	"math/rand"
+	"net"
+	"reflect"
+	"sort"
 	"strconv"
 	"strings"
 	"testing"
@@ -45,6 +51,32 @@ func randInt(min int, max int) int {
 	return min + rand.Intn(max-min)
 }
 
+// keysOnDistinctServers returns n keys made of prefix and a number, where
+// the key at index i is on the i-th server of c.
+func keysOnDistinctServers(t *testing.T, c *Client, prefix string, n int) []string {
+	servers, err := c.Servers()
+	if err != nil {
+		t.Fatal(err)
+	} else if len(servers) < n {
+		t.Fatalf(errUnexpected, servers)
+	}
+	keys := make([]string, n)
+	for i, found := 0, 0; found < n; i++ {
+		k := prefix + strconv.Itoa(i)
+		srv, err := c.selector.PickServer(k)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for j := range keys {
+			if keys[j] == "" && serverID(srv) == serverID(servers[j]) {
+				keys[j] = k
+				found++
+			}
+		}
+	}
+	return keys
+}
+
 const errUnexpected = "Unexpected response from redis-server: %#v"
 
 // Tests
@@ -52,8 +84,10 @@ const errUnexpected = "Unexpected response from redis-server: %#v"
 func TestPublish(t *testing.T) {
 	k := randomString(16)
 	v := randomString(16)
-	if err := rc.Publish(k, v); err != nil {
+	if n, err := rc.Publish(k, v); err != nil {
 		t.Fatal(err)
+	} else if n != 0 {
+		t.Fatalf(errUnexpected, n)
 	}
 }
 
@@ -91,28 +125,114 @@ func __TestBgSave(t *testing.T) {
 // TestBitCount reproduces the example from http://redis.io/commands/bitcount.
 func TestBitCount(t *testing.T) {
 	defer rc.Del("mykey")
-	if err := rc.Set("mykey", "foobar"); err != nil {
+	if _, _, err := rc.Set("mykey", "foobar", SetArgs{}); err != nil {
 		t.Fatal(err)
 	}
-	if n, err := rc.BitCount("mykey", -1, -1); err != nil {
+	if n, err := rc.BitCountAll("mykey"); err != nil {
 		t.Fatal(err)
 	} else if n != 26 {
 		t.Fatalf(errUnexpected, n)
 	}
+	if n, err := rc.BitCount("mykey", 1, 1); err != nil {
+		t.Fatal(err)
+	} else if n != 6 {
+		t.Fatalf(errUnexpected, n)
+	}
+	if n, err := rc.BitCount("mykey", -2, -1); err != nil {
+		t.Fatal(err)
+	} else if n != 7 {
+		t.Fatalf(errUnexpected, n)
+	}
+}
+
+// TestBitPos reproduces the example from http://redis.io/commands/bitpos.
+func TestBitPos(t *testing.T) {
+	defer rc.Del("mykey")
+	rc.Set("mykey", "\xff\xf0\x00", SetArgs{})
+	if n, err := rc.BitPos("mykey", 0, nil); err != nil {
+		t.Fatal(err)
+	} else if n != 12 {
+		t.Fatalf(errUnexpected, n)
+	}
+	rc.Set("mykey", "\x00\xff\xf0", SetArgs{})
+	if n, err := rc.BitPos("mykey", 1, &BitRange{Start: 2, End: -1}); err != nil {
+		t.Fatal(err)
+	} else if n != 16 {
+		t.Fatalf(errUnexpected, n)
+	}
+	if n, err := rc.BitPos("mykey", 1, &BitRange{Start: 7, End: 15, Bit: true}); err != nil {
+		t.Fatal(err)
+	} else if n != 8 {
+		t.Fatalf(errUnexpected, n)
+	}
+	rc.Set("mykey", "\x00\x00\x00", SetArgs{})
+	if n, err := rc.BitPos("mykey", 1, nil); err != nil {
+		t.Fatal(err)
+	} else if n != -1 {
+		t.Fatalf(errUnexpected, n)
+	}
+}
+
+func TestBitField(t *testing.T) {
+	defer rc.Del("mykey")
+	rc.Del("mykey")
+	ops := new(BitFieldOps).IncrBy("i5", 100, 1).Get("u4", 0)
+	if v, err := rc.BitField("mykey", ops); err != nil {
+		t.Fatal(err)
+	} else if len(v) != 2 || *v[0] != 1 || *v[1] != 0 {
+		t.Fatalf(errUnexpected, v)
+	}
+	ops = new(BitFieldOps).Set("u8", 0, 255).
+		IncrBy("u2", 100, 1).
+		Overflow("SAT").IncrBy("u2", 102, 5).
+		Overflow("FAIL").IncrBy("u8", 0, 1)
+	if v, err := rc.BitField("mykey", ops); err != nil {
+		t.Fatal(err)
+	} else if len(v) != 4 || *v[0] != 0 || *v[1] != 1 || *v[2] != 3 || v[3] != nil {
+		t.Fatalf(errUnexpected, v)
+	}
+	if v, err := rc.BitFieldRO("mykey", new(BitFieldOps).Get("u8", 0)); err != nil {
+		t.Fatal(err)
+	} else if len(v) != 1 || *v[0] != 255 {
+		t.Fatalf(errUnexpected, v)
+	}
+	if _, err := rc.BitFieldRO("mykey", new(BitFieldOps).Set("u8", 0, 1)); err == nil {
+		t.Fatal("BitFieldRO accepted a write")
+	}
+	if v, err := rc.BitField("mykey", nil); err != nil {
+		t.Fatal(err)
+	} else if len(v) != 0 {
+		t.Fatalf(errUnexpected, v)
+	}
+	if v, err := rc.BitFieldRO("mykey", nil); err != nil {
+		t.Fatal(err)
+	} else if len(v) != 0 {
+		t.Fatalf(errUnexpected, v)
+	}
 }
 
 // TestBitOp reproduces the example from http://redis.io/commands/bitop.
 func TestBitOp(t *testing.T) {
 	defer rc.Del("key1", "key2", "dest")
-	if err := rc.Set("key1", "foobar"); err != nil {
+	if _, _, err := rc.Set("key1", "foobar", SetArgs{}); err != nil {
 		t.Fatal(err)
 	}
-	if err := rc.Set("key2", "abcdef"); err != nil {
+	if _, _, err := rc.Set("key2", "abcdef", SetArgs{}); err != nil {
 		t.Fatal(err)
 	}
 	if _, err := rc.BitOp("and", "dest", "key1", "key2"); err != nil {
 		t.Fatal(err)
 	}
+	if v, err := rc.Get("dest"); err != nil {
+		t.Fatal(err)
+	} else if v != "`bc`ab" {
+		t.Fatalf(errUnexpected, v)
+	}
+	if n, err := rc.BitOp("NOT", "dest", "key1"); err != nil {
+		t.Fatal(err)
+	} else if n != 6 {
+		t.Fatalf(errUnexpected, n)
+	}
 }
 
 // TestRPush and LIndex
@@ -268,6 +388,174 @@ func TestBRPopLPushTimeout(t *testing.T) {
 	}
 }
 
+func TestBRPopLPushCrossShard(t *testing.T) {
+	c := New("127.0.0.1:6379", "127.0.0.1:6379 db=1")
+	keys := keysOnDistinctServers(t, c, "list", 2)
+	if _, err := c.BRPopLPush(keys[0], keys[1], 1); err != ErrCrossShard {
+		t.Fatalf(errUnexpected, err)
+	}
+	if _, err := c.LMove(keys[0], keys[1], "LEFT", "RIGHT"); err != ErrCrossShard {
+		t.Fatalf(errUnexpected, err)
+	}
+	if c.Timeout != 0 {
+		t.Fatalf(errUnexpected, c.Timeout)
+	}
+}
+
+func TestLMove(t *testing.T) {
+	k1, k2 := "{list}1", "{list}2"
+	rc.Del(k1, k2)
+	defer rc.Del(k1, k2)
+	rc.RPush(k1, "a", "b", "c")
+	if v, err := rc.LMove(k1, k2, "LEFT", "RIGHT"); err != nil {
+		t.Fatal(err)
+	} else if v != "a" {
+		t.Fatalf(errUnexpected, v)
+	}
+	if v, err := rc.RPopLPush(k1, k2); err != nil {
+		t.Fatal(err)
+	} else if v != "c" {
+		t.Fatalf(errUnexpected, v)
+	}
+	if v, err := rc.BLMove(k1, k2, "RIGHT", "LEFT", 1); err != nil {
+		t.Fatal(err)
+	} else if v != "b" {
+		t.Fatalf(errUnexpected, v)
+	}
+	if v, err := rc.LRange(k2, 0, -1); err != nil {
+		t.Fatal(err)
+	} else if strings.Join(v, "") != "bca" {
+		t.Fatalf(errUnexpected, v)
+	}
+	if v, err := rc.LMove(k1, k2, "LEFT", "LEFT"); err != nil {
+		t.Fatal(err)
+	} else if v != "" {
+		t.Fatalf(errUnexpected, v)
+	}
+	if _, err := rc.BLMove(k1, k2, "LEFT", "LEFT", 1); err != ErrTimedOut {
+		t.Fatalf(errUnexpected, err)
+	}
+}
+
+func TestLMPop(t *testing.T) {
+	k1, k2 := "{list}1", "{list}2"
+	rc.Del(k1, k2)
+	defer rc.Del(k1, k2)
+	rc.RPush(k2, "a", "b", "c")
+	if k, v, err := rc.LMPop("RIGHT", 2, k1, k2); err != nil {
+		t.Fatal(err)
+	} else if k != k2 || len(v) != 2 || v[0] != "c" || v[1] != "b" {
+		t.Fatalf(errUnexpected, v)
+	}
+	if k, v, err := rc.BLMPop(1, "LEFT", 0, k1, k2); err != nil {
+		t.Fatal(err)
+	} else if k != k2 || len(v) != 1 || v[0] != "a" {
+		t.Fatalf(errUnexpected, v)
+	}
+	if k, v, err := rc.LMPop("LEFT", 0, k1, k2); err != nil {
+		t.Fatal(err)
+	} else if k != "" || len(v) != 0 {
+		t.Fatalf(errUnexpected, v)
+	}
+	if _, _, err := rc.BLMPop(1, "LEFT", 0, k1, k2); err != ErrTimedOut {
+		t.Fatalf(errUnexpected, err)
+	}
+}
+
+func TestLPopCount(t *testing.T) {
+	rc.Del("list1")
+	defer rc.Del("list1")
+	rc.RPush("list1", "a", "b", "c", "d")
+	if v, err := rc.LPopCount("list1", 2); err != nil {
+		t.Fatal(err)
+	} else if strings.Join(v, "") != "ab" {
+		t.Fatalf(errUnexpected, v)
+	}
+	if v, err := rc.RPopCount("list1", 5); err != nil {
+		t.Fatal(err)
+	} else if strings.Join(v, "") != "dc" {
+		t.Fatalf(errUnexpected, v)
+	}
+	if v, err := rc.LPopCount("list1", 2); err != nil {
+		t.Fatal(err)
+	} else if len(v) != 0 {
+		t.Fatalf(errUnexpected, v)
+	}
+}
+
+func TestLInsertSetRem(t *testing.T) {
+	rc.Del("list1")
+	defer rc.Del("list1")
+	if n, err := rc.LPushX("list1", "a"); err != nil {
+		t.Fatal(err)
+	} else if n != 0 {
+		t.Fatalf(errUnexpected, n)
+	}
+	rc.RPush("list1", "a", "b", "a")
+	if n, err := rc.RPushX("list1", "c"); err != nil {
+		t.Fatal(err)
+	} else if n != 4 {
+		t.Fatalf(errUnexpected, n)
+	}
+	if n, err := rc.LInsert("list1", "BEFORE", "b", "x"); err != nil {
+		t.Fatal(err)
+	} else if n != 5 {
+		t.Fatalf(errUnexpected, n)
+	}
+	if n, err := rc.LInsert("list1", "AFTER", "nopivot", "x"); err != nil {
+		t.Fatal(err)
+	} else if n != -1 {
+		t.Fatalf(errUnexpected, n)
+	}
+	if err := rc.LSet("list1", -1, "y"); err != nil {
+		t.Fatal(err)
+	}
+	if err := rc.LSet("list1", 10, "y"); err == nil {
+		t.Fatal("LSet succeeded out of range")
+	}
+	if n, err := rc.LRem("list1", 0, "a"); err != nil {
+		t.Fatal(err)
+	} else if n != 2 {
+		t.Fatalf(errUnexpected, n)
+	}
+	if v, err := rc.LRange("list1", 0, -1); err != nil {
+		t.Fatal(err)
+	} else if strings.Join(v, "") != "xby" {
+		t.Fatalf(errUnexpected, v)
+	}
+}
+
+func TestLPos(t *testing.T) {
+	rc.Del("list1")
+	defer rc.Del("list1")
+	rc.RPush("list1", "a", "b", "c", "1", "2", "3", "c", "c")
+	if n, err := rc.LPos("list1", "c", LPosOptions{}); err != nil {
+		t.Fatal(err)
+	} else if n != 2 {
+		t.Fatalf(errUnexpected, n)
+	}
+	if n, err := rc.LPos("list1", "c", LPosOptions{Rank: -1}); err != nil {
+		t.Fatal(err)
+	} else if n != 7 {
+		t.Fatalf(errUnexpected, n)
+	}
+	if n, err := rc.LPos("list1", "c", LPosOptions{MaxLen: 2}); err != nil {
+		t.Fatal(err)
+	} else if n != -1 {
+		t.Fatalf(errUnexpected, n)
+	}
+	if v, err := rc.LPosCount("list1", "c", 0, LPosOptions{Rank: 2}); err != nil {
+		t.Fatal(err)
+	} else if !reflect.DeepEqual(v, []int{6, 7}) {
+		t.Fatalf(errUnexpected, v)
+	}
+	if v, err := rc.LPosCount("list1", "x", 0, LPosOptions{}); err != nil {
+		t.Fatal(err)
+	} else if len(v) != 0 {
+		t.Fatalf(errUnexpected, v)
+	}
+}
+
 // TestClientListKill kills the first connection returned by CLIENT LIST.
 func TestClientListKill(t *testing.T) {
 	var addr []string
@@ -316,6 +604,15 @@ func TestConfigGet(t *testing.T) {
 	}
 }
 
+// TestInfo checks the server section of INFO.
+func TestInfo(t *testing.T) {
+	if info, err := rc.Info("server"); err != nil {
+		t.Fatal(err)
+	} else if !strings.Contains(info, "redis_version:") {
+		t.Fatalf(errUnexpected, info)
+	}
+}
+
 // TestConfigSet sets redis dir to /tmp, and back to the default.
 func TestConfigSet(t *testing.T) {
 	items, err := rc.ConfigGet("dir")
@@ -343,7 +640,7 @@ func TestDBSize(t *testing.T) {
 	if err != nil {
 		t.Fatalf(errUnexpected, err)
 	}
-	rc.Set("test-db-size", "zzz")
+	rc.Set("test-db-size", "zzz", SetArgs{})
 	defer rc.Del("test-db-size")
 	if new_size, err := rc.DBSize(); err != nil {
 		t.Fatalf(errUnexpected, err)
@@ -352,6 +649,112 @@ func TestDBSize(t *testing.T) {
 	}
 }
 
+// TestDBSizeAll checks that the size of every server is returned.
+func TestDBSizeAll(t *testing.T) {
+	if sizes, err := rc.DBSizeAll(); err != nil {
+		t.Fatal(err)
+	} else if _, ok := sizes["127.0.0.1:6379"]; !ok || len(sizes) != 1 {
+		t.Fatalf(errUnexpected, sizes)
+	}
+}
+
+// TestDBSizeAllDBs checks that databases of the same server are counted
+// separately.
+func TestDBSizeAllDBs(t *testing.T) {
+	c := New("127.0.0.1:6379 db=5", "127.0.0.1:6379 db=6")
+	if err := c.FlushDB(); err != nil {
+		t.Fatal(err)
+	}
+	defer c.FlushDB()
+	db5, db6 := New("127.0.0.1:6379 db=5"), New("127.0.0.1:6379 db=6")
+	db5.Set("a", "1", SetArgs{})
+	db5.Set("b", "2", SetArgs{})
+	db6.Set("c", "3", SetArgs{})
+	if n, err := c.DBSize(); err != nil {
+		t.Fatal(err)
+	} else if n != 3 {
+		t.Fatalf(errUnexpected, n)
+	}
+	want := map[string]int{"127.0.0.1:6379 db=5": 2, "127.0.0.1:6379 db=6": 1}
+	if sizes, err := c.DBSizeAll(); err != nil {
+		t.Fatal(err)
+	} else if !reflect.DeepEqual(sizes, want) {
+		t.Fatalf(errUnexpected, sizes)
+	}
+	if keys, err := c.Keys("*"); err != nil {
+		t.Fatal(err)
+	} else if sort.Strings(keys); !reflect.DeepEqual(keys, []string{"a", "b", "c"}) {
+		t.Fatalf(errUnexpected, keys)
+	}
+	// Instance-wide commands run once, and are keyed by address.
+	if info, err := c.InfoAll("server"); err != nil {
+		t.Fatal(err)
+	} else if _, ok := info["127.0.0.1:6379"]; !ok || len(info) != 1 {
+		t.Fatalf(errUnexpected, info)
+	}
+}
+
+// TestHandshakeError checks that new connections are closed when the
+// server rejects their SELECT.
+func TestHandshakeError(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	closed := make(chan error, 1)
+	go func() {
+		nc, err := ln.Accept()
+		if err != nil {
+			closed <- err
+			return
+		}
+		defer nc.Close()
+		io.WriteString(nc, "-ERR DB index is out of range\r\n")
+		_, err = io.Copy(ioutil.Discard, nc)
+		closed <- err
+	}()
+	c := New(ln.Addr().String() + " db=100")
+	if _, err := c.Get("foo"); err == nil {
+		t.Fatal("Get succeeded on a failed SELECT")
+	}
+	select {
+	case err := <-closed:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("connection was not closed")
+	}
+}
+
+// TestServers checks that servers listed multiple times are only
+// returned once.
+func TestServers(t *testing.T) {
+	c := New("127.0.0.1:6379", "127.0.0.1:6380", "127.0.0.1:6379")
+	if servers, err := c.Servers(); err != nil {
+		t.Fatal(err)
+	} else if len(servers) != 2 {
+		t.Fatalf(errUnexpected, servers)
+	}
+}
+
+// TestForEachServer checks that failures are reported per server.
+func TestForEachServer(t *testing.T) {
+	c := New("127.0.0.1:6379", "127.0.0.1:6380")
+	err := c.ForEachServer(func(srv ServerInfo) error {
+		if srv.Addr.String() == "127.0.0.1:6380" {
+			return ErrTimedOut
+		}
+		return nil
+	})
+	if errs, ok := err.(ServerErrors); !ok {
+		t.Fatalf(errUnexpected, err)
+	} else if len(errs) != 1 || errs["127.0.0.1:6380"] != ErrTimedOut {
+		t.Fatalf(errUnexpected, errs)
+	}
+}
+
 // TestDebugSegfault crashes redis and breaks everything else.
 func __TestDebugSegfault(t *testing.T) {
 	if err := rc.DebugSegfault(); err != nil {
@@ -363,7 +766,7 @@ func __TestDebugSegfault(t *testing.T) {
 func TestDecr(t *testing.T) {
 	rc.Del("mykey")
 	defer rc.Del("mykey")
-	rc.Set("mykey", "10")
+	rc.Set("mykey", "10", SetArgs{})
 	if n, err := rc.Decr("mykey"); err != nil {
 		t.Fatalf(errUnexpected, err)
 	} else if n != 9 {
@@ -375,7 +778,7 @@ func TestDecr(t *testing.T) {
 func TestDecrBy(t *testing.T) {
 	rc.Del("mykey")
 	defer rc.Del("mykey")
-	rc.Set("mykey", "10")
+	rc.Set("mykey", "10", SetArgs{})
 	if n, err := rc.DecrBy("mykey", 5); err != nil {
 		t.Fatalf(errUnexpected, err)
 	} else if n != 5 {
@@ -387,7 +790,7 @@ func TestDecrBy(t *testing.T) {
 func TestIncr(t *testing.T) {
 	rc.Del("mykey")
 	defer rc.Del("mykey")
-	rc.Set("mykey", "0")
+	rc.Set("mykey", "0", SetArgs{})
 	if n, err := rc.Incr("mykey"); err != nil {
 		t.Fatalf(errUnexpected, err)
 	} else if n != 1 {
@@ -399,7 +802,7 @@ func TestIncr(t *testing.T) {
 func TestIncrBy(t *testing.T) {
 	rc.Del("mykey")
 	defer rc.Del("mykey")
-	rc.Set("mykey", "0")
+	rc.Set("mykey", "0", SetArgs{})
 	if n, err := rc.IncrBy("mykey", 5); err != nil {
 		t.Fatalf(errUnexpected, err)
 	} else if n != 5 {
@@ -413,7 +816,7 @@ func TestDel(t *testing.T) {
 	for n := 0; n < cap(keys); n++ {
 		k := randomString(4) + string(n)
 		v := randomString(32)
-		if err := rc.Set(k, v); err != nil {
+		if _, _, err := rc.Set(k, v, SetArgs{}); err != nil {
 			t.Fatal(err)
 		} else {
 			keys[n] = k
@@ -428,10 +831,59 @@ func TestDel(t *testing.T) {
 
 // TODO: TestDiscard
 
+func TestDo(t *testing.T) {
+	if s, err := rc.Do("PING").Text(); err != nil {
+		t.Fatal(err)
+	} else if s != "PONG" {
+		t.Fatalf(errUnexpected, s)
+	}
+	if s, err := rc.Do("ECHO", []byte("hello")).Text(); err != nil {
+		t.Fatal(err)
+	} else if s != "hello" {
+		t.Fatalf(errUnexpected, s)
+	}
+	if _, err := rc.Do("NOSUCHCOMMAND").Text(); err == nil {
+		t.Fatal("Do accepted an unknown command")
+	} else if _, ok := err.(Error); !ok {
+		t.Fatalf(errUnexpected, err)
+	}
+	for _, arg := range []interface{}{true, int32(1), uint(1), time.Second, nil} {
+		if err := rc.Do("ECHO", arg).Err(); err == nil {
+			t.Fatalf(errUnexpected, arg)
+		}
+		if err := rc.DoWithKey("mykey", "SET", arg).Err(); err == nil {
+			t.Fatalf(errUnexpected, arg)
+		}
+	}
+}
+
+func TestDoWithKey(t *testing.T) {
+	c := New("127.0.0.1:6379", "127.0.0.1:6379 db=1")
+	keys := keysOnDistinctServers(t, c, "do", 2)
+	defer c.Del(keys[0], keys[1])
+	for i, k := range keys {
+		if err := c.DoWithKey(k, "SET", "db"+strconv.Itoa(i), "EX", int64(60)).Err(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for i, k := range keys {
+		if v, err := c.Get(k); err != nil {
+			t.Fatal(err)
+		} else if v != "db"+strconv.Itoa(i) {
+			t.Fatalf(errUnexpected, v)
+		}
+		if n, err := c.DoWithKey(k, "STRLEN").Int64(); err != nil {
+			t.Fatal(err)
+		} else if n != int64(len("db"+strconv.Itoa(i))) {
+			t.Fatalf(errUnexpected, n)
+		}
+	}
+}
+
 // TestDump reproduces the example from http://redis.io/commands/dump.
 func TestDump(t *testing.T) {
 	defer rc.Del("mykey")
-	rc.Set("mykey", "10")
+	rc.Set("mykey", "10", SetArgs{})
 	if v, err := rc.Dump("mykey"); err != nil {
 		t.Fatal(err)
 	} else if v != "\u0000\xC0\n\u0006\u0000\xF8r?\xC5\xFB\xFB_(" {
@@ -465,21 +917,24 @@ func TestEval(t *testing.T) {
 
 // TestEvalSha tests server side Lua script.
 // TestEvalSha preloads the script with ScriptLoad.
-// TODO: fix the response.
 func TestEvalSha(t *testing.T) {
 	sha1, err := rc.ScriptLoad("return {1,{2,3,'foo'},KEYS[1],KEYS[2],ARGV[1],ARGV[2]}")
 	if err != nil {
 		t.Fatal(err)
 	}
-	if _, err = rc.EvalSha(
+	v, err := rc.EvalSha(
 		sha1, // pre-loaded script
 		2,    // numkeys
 		[]string{"key1", "key2"},    // keys
 		[]string{"first", "second"}, // args
-	); err != nil {
+	)
+	if err != nil {
 		t.Fatal(err)
 	}
-	//t.Log("v=%#v\n", v)
+	want := []interface{}{1, []interface{}{2, 3, "foo"}, "key1", "key2", "first", "second"}
+	if !reflect.DeepEqual(v, want) {
+		t.Fatalf(errUnexpected, v)
+	}
 }
 
 // TODO: TestExec
@@ -488,16 +943,22 @@ func TestEvalSha(t *testing.T) {
 func TestExists(t *testing.T) {
 	rc.Del("key1", "key2")
 	defer rc.Del("key1", "key2")
-	rc.Set("key1", "Hello")
-	if ok, err := rc.Exists("key1"); err != nil {
+	rc.Set("key1", "Hello", SetArgs{})
+	if n, err := rc.Exists("key1"); err != nil {
 		t.Fatal(err)
-	} else if !ok {
-		t.Fatalf(errUnexpected, ok)
+	} else if n != 1 {
+		t.Fatalf(errUnexpected, n)
 	}
-	if ok, err := rc.Exists("key2"); err != nil {
+	if n, err := rc.Exists("nosuchkey"); err != nil {
 		t.Fatal(err)
-	} else if ok {
-		t.Fatalf(errUnexpected, ok)
+	} else if n != 0 {
+		t.Fatalf(errUnexpected, n)
+	}
+	rc.Set("key2", "World", SetArgs{})
+	if n, err := rc.Exists("key1", "key2", "nosuchkey"); err != nil {
+		t.Fatal(err)
+	} else if n != 2 {
+		t.Fatalf(errUnexpected, n)
 	}
 }
 
@@ -505,7 +966,7 @@ func TestExists(t *testing.T) {
 // TestExpire also tests the TTL command.
 func TestExpire(t *testing.T) {
 	defer rc.Del("mykey")
-	rc.Set("mykey", "hello")
+	rc.Set("mykey", "hello", SetArgs{})
 	if ok, err := rc.Expire("mykey", 10); err != nil {
 		t.Fatal(err)
 	} else if !ok {
@@ -516,7 +977,7 @@ func TestExpire(t *testing.T) {
 	} else if ttl != 10 {
 		t.Fatalf(errUnexpected, ttl)
 	}
-	rc.Set("mykey", "Hello World")
+	rc.Set("mykey", "Hello World", SetArgs{})
 	if ttl, err := rc.TTL("mykey"); err != nil {
 		t.Fatal(err)
 	} else if ttl != -1 {
@@ -527,26 +988,238 @@ func TestExpire(t *testing.T) {
 // TestExpireAt reproduces the example from http://redis.io/commands/expire.
 func TestExpireAt(t *testing.T) {
 	defer rc.Del("mykey")
-	rc.Set("mykey", "hello")
-	if ok, err := rc.Exists("mykey"); err != nil {
+	rc.Set("mykey", "hello", SetArgs{})
+	if n, err := rc.Exists("mykey"); err != nil {
 		t.Fatal(err)
-	} else if !ok {
-		t.Fatalf(errUnexpected, ok)
+	} else if n != 1 {
+		t.Fatalf(errUnexpected, n)
 	}
 	if ok, err := rc.ExpireAt("mykey", 1293840000); err != nil {
 		t.Fatal(err)
 	} else if !ok {
 		t.Fatalf(errUnexpected, ok)
 	}
-	if ok, err := rc.Exists("mykey"); err != nil {
+	if n, err := rc.Exists("mykey"); err != nil {
 		t.Fatal(err)
-	} else if ok {
-		t.Fatal(errUnexpected, ok)
+	} else if n != 0 {
+		t.Fatalf(errUnexpected, n)
 	}
 }
 
-// FlushAll and FlushDB are not required because they never fail.
-
+func TestPExpire(t *testing.T) {
+	defer rc.Del("mykey")
+	rc.Set("mykey", "hello", SetArgs{})
+	if ok, err := rc.PExpire("mykey", 1500*time.Millisecond); err != nil {
+		t.Fatal(err)
+	} else if !ok {
+		t.Fatalf(errUnexpected, ok)
+	}
+	if ttl, err := rc.PTTL("mykey"); err != nil {
+		t.Fatal(err)
+	} else if ttl <= time.Second || ttl > 1500*time.Millisecond {
+		t.Fatalf(errUnexpected, ttl)
+	}
+	at := time.Now().Add(time.Hour)
+	if ok, err := rc.PExpireAt("mykey", at); err != nil {
+		t.Fatal(err)
+	} else if !ok {
+		t.Fatalf(errUnexpected, ok)
+	}
+	if n, err := rc.ExpireTime("mykey"); err != nil {
+		t.Fatal(err)
+	} else if n != int(at.Unix()) {
+		t.Fatalf(errUnexpected, n)
+	}
+	if ok, err := rc.Persist("mykey"); err != nil {
+		t.Fatal(err)
+	} else if !ok {
+		t.Fatalf(errUnexpected, ok)
+	}
+	if ttl, err := rc.PTTL("mykey"); err != nil {
+		t.Fatal(err)
+	} else if ttl != NoExpiry {
+		t.Fatalf(errUnexpected, ttl)
+	}
+	rc.Del("mykey")
+	if _, err := rc.PTTL("mykey"); err != ErrNil {
+		t.Fatalf(errUnexpected, err)
+	}
+}
+
+func TestType(t *testing.T) {
+	rc.Del("key1", "key2")
+	defer rc.Del("key1", "key2")
+	rc.Set("key1", "value", SetArgs{})
+	rc.LPush("key2", "value")
+	for k, want := range map[string]string{"key1": "string", "key2": "list", "key3": "none"} {
+		if v, err := rc.Type(k); err != nil {
+			t.Fatal(err)
+		} else if v != want {
+			t.Fatalf(errUnexpected, v)
+		}
+	}
+}
+
+func TestRenameCopy(t *testing.T) {
+	k1, k2, k3 := "{mykey}1", "{mykey}2", "{mykey}3"
+	rc.Del(k1, k2, k3)
+	defer rc.Del(k1, k2, k3)
+	rc.Set(k1, "hello", SetArgs{})
+	rc.Set(k3, "world", SetArgs{})
+	if err := rc.Rename(k1, k2); err != nil {
+		t.Fatal(err)
+	}
+	if ok, err := rc.RenameNX(k2, k3); err != nil {
+		t.Fatal(err)
+	} else if ok {
+		t.Fatalf(errUnexpected, ok)
+	}
+	if ok, err := rc.Copy(k2, k3, false); err != nil {
+		t.Fatal(err)
+	} else if ok {
+		t.Fatalf(errUnexpected, ok)
+	}
+	if ok, err := rc.Copy(k2, k3, true); err != nil {
+		t.Fatal(err)
+	} else if !ok {
+		t.Fatalf(errUnexpected, ok)
+	}
+	if v, _ := rc.MGet(k1, k2, k3); v[0] != "" || v[1] != "hello" || v[2] != "hello" {
+		t.Fatalf(errUnexpected, v)
+	}
+	c := New("127.0.0.1:6379", "127.0.0.1:6379 db=1")
+	keys := keysOnDistinctServers(t, c, "mykey", 2)
+	if err := c.Rename(keys[0], keys[1]); err != ErrCrossShard {
+		t.Fatalf(errUnexpected, err)
+	}
+}
+
+func TestRestore(t *testing.T) {
+	rc.Del("mykey", "mykey2")
+	defer rc.Del("mykey", "mykey2")
+	rc.Set("mykey", "hello\r\n\x00", SetArgs{})
+	v, err := rc.Dump("mykey")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = rc.Restore("mykey2", time.Minute, v, false); err != nil {
+		t.Fatal(err)
+	}
+	if err = rc.Restore("mykey2", 0, v, false); err == nil {
+		t.Fatal("Restore replaced an existing key")
+	}
+	if err = rc.Restore("mykey2", 0, v, true); err != nil {
+		t.Fatal(err)
+	}
+	if v, _ := rc.Get("mykey2"); v != "hello\r\n\x00" {
+		t.Fatalf(errUnexpected, v)
+	}
+}
+
+func TestMove(t *testing.T) {
+	c := New("127.0.0.1:6379 db=1")
+	rc.Del("mykey")
+	c.Del("mykey")
+	defer c.Del("mykey")
+	rc.Set("mykey", "hello", SetArgs{})
+	if ok, err := rc.Move("mykey", 1); err != nil {
+		t.Fatal(err)
+	} else if !ok {
+		t.Fatalf(errUnexpected, ok)
+	}
+	if v, _ := c.Get("mykey"); v != "hello" {
+		t.Fatalf(errUnexpected, v)
+	}
+}
+
+func TestObject(t *testing.T) {
+	rc.Del("mykey")
+	defer rc.Del("mykey")
+	if _, err := rc.ObjectEncoding("mykey"); err != ErrNil {
+		t.Fatalf(errUnexpected, err)
+	}
+	rc.LPush("mykey", "a")
+	if v, err := rc.ObjectEncoding("mykey"); err != nil {
+		t.Fatal(err)
+	} else if v == "" {
+		t.Fatalf(errUnexpected, v)
+	}
+	if d, err := rc.ObjectIdleTime("mykey"); err != nil {
+		t.Fatal(err)
+	} else if d > time.Minute {
+		t.Fatalf(errUnexpected, d)
+	}
+}
+
+func TestRandomKey(t *testing.T) {
+	c := New("127.0.0.1:6379 db=2", "127.0.0.1:6379 db=3")
+	if err := c.FlushDB(); err != nil {
+		t.Fatal(err)
+	}
+	if k, err := c.RandomKey(); err != nil {
+		t.Fatal(err)
+	} else if k != "" {
+		t.Fatalf(errUnexpected, k)
+	}
+	c.Set("mykey", "hello", SetArgs{})
+	defer c.Del("mykey")
+	for n := 0; n < 4; n++ {
+		if k, err := c.RandomKey(); err != nil {
+			t.Fatal(err)
+		} else if k != "mykey" {
+			t.Fatalf(errUnexpected, k)
+		}
+	}
+}
+
+func TestPFAddCount(t *testing.T) {
+	k1, k2, dst := "{hll}1", "{hll}2", "{hll}dst"
+	rc.Del(k1, k2, dst)
+	defer rc.Del(k1, k2, dst)
+	if ok, err := rc.PFAdd(k1, "a", "b", "c"); err != nil {
+		t.Fatal(err)
+	} else if !ok {
+		t.Fatalf(errUnexpected, ok)
+	}
+	if ok, err := rc.PFAdd(k1, "a"); err != nil {
+		t.Fatal(err)
+	} else if ok {
+		t.Fatalf(errUnexpected, ok)
+	}
+	rc.PFAdd(k2, "c", "d")
+	if n, err := rc.PFCount(k1); err != nil {
+		t.Fatal(err)
+	} else if n != 3 {
+		t.Fatalf(errUnexpected, n)
+	}
+	if n, err := rc.PFCount(k1, k2); err != nil {
+		t.Fatal(err)
+	} else if n != 4 {
+		t.Fatalf(errUnexpected, n)
+	}
+	if err := rc.PFMerge(dst, k1, k2); err != nil {
+		t.Fatal(err)
+	}
+	if n, err := rc.PFCount(dst); err != nil {
+		t.Fatal(err)
+	} else if n != 4 {
+		t.Fatalf(errUnexpected, n)
+	}
+}
+
+func TestPFCountCrossShard(t *testing.T) {
+	c := New("127.0.0.1:6379", "127.0.0.1:6379 db=1")
+	keys := keysOnDistinctServers(t, c, "hll", 2)
+	if _, err := c.PFCount(keys[0], keys[1]); err != ErrCrossShard {
+		t.Fatalf(errUnexpected, err)
+	}
+	if err := c.PFMerge(keys[0], keys[1]); err != ErrCrossShard {
+		t.Fatalf(errUnexpected, err)
+	}
+}
+
+// FlushAll and FlushDB are not required because they never fail.
+
 // TestGet reproduces the example from http://redis.io/commands/get
 func TestGet(t *testing.T) {
 	rc.Del("nonexisting")
@@ -555,7 +1228,7 @@ func TestGet(t *testing.T) {
 	} else if v != "" {
 		t.Fatalf(errUnexpected, v)
 	}
-	rc.Set("mykey", "Hello")
+	rc.Set("mykey", "Hello", SetArgs{})
 	defer rc.Del("mykey")
 	if v, err := rc.Get("mykey"); err != nil {
 		t.Fatal(err)
@@ -586,7 +1259,7 @@ func TestGetBit(t *testing.T) {
 // TestGetRange reproduces the example from http://redis.io/commands/getrange.
 func TestGetRange(t *testing.T) {
 	defer rc.Del("mykey")
-	rc.Set("mykey", "This is a string")
+	rc.Set("mykey", "This is a string", SetArgs{})
 	if v, err := rc.GetRange("mykey", 0, 3); err != nil {
 		t.Fatal(err)
 	} else if v != "This" {
@@ -629,8 +1302,8 @@ func TestGetSet(t *testing.T) {
 // TestMGet reproduces the example from http://redis.io/commands/mget.
 func TestMGet(t *testing.T) {
 	defer rc.Del("key1", "key2")
-	rc.Set("key1", "Hello")
-	rc.Set("key2", "World")
+	rc.Set("key1", "Hello", SetArgs{})
+	rc.Set("key2", "World", SetArgs{})
 	if items, err := rc.MGet("key1", "key2"); err != nil {
 		t.Fatal(err)
 	} else if items[0] != "Hello" || items[1] != "World" {
@@ -654,6 +1327,49 @@ func TestMSet(t *testing.T) {
 	}
 }
 
+// TestGroupKeys checks that keys are grouped by server, in order.
+func TestGroupKeys(t *testing.T) {
+	c := New("127.0.0.1:6379", "127.0.0.1:6380")
+	keys := make([]string, 32)
+	for n := range keys {
+		keys[n] = randomString(8)
+	}
+	groups, err := c.groupKeys("MGET", keys)
+	if err != nil {
+		t.Fatal(err)
+	} else if len(groups) != 2 {
+		t.Fatalf(errUnexpected, len(groups))
+	}
+	for _, g := range groups {
+		last := -1
+		for n, k := range g.keys {
+			srv, _ := c.selector.PickServer(k)
+			if serverID(srv) != serverID(g.srv) {
+				t.Fatalf(errUnexpected, k)
+			} else if keys[g.idx[n]] != k || g.idx[n] <= last {
+				t.Fatalf(errUnexpected, g.idx)
+			}
+			last = g.idx[n]
+		}
+	}
+}
+
+// TestUnlinkTouch tests the UNLINK and TOUCH commands.
+func TestUnlinkTouch(t *testing.T) {
+	rc.MSet(map[string]string{"key1": "Hello", "key2": "World"})
+	defer rc.Del("key1", "key2")
+	if n, err := rc.Touch("key1", "key2", "nosuchkey"); err != nil {
+		t.Fatal(err)
+	} else if n != 2 {
+		t.Fatalf(errUnexpected, n)
+	}
+	if n, err := rc.Unlink("key1", "key2", "nosuchkey"); err != nil {
+		t.Fatal(err)
+	} else if n != 2 {
+		t.Fatalf(errUnexpected, n)
+	}
+}
+
 // TestKeys reproduces the example from http://redis.io/commands/keys
 func TestKeys(t *testing.T) {
 	rc.MSet(map[string]string{
@@ -710,12 +1426,143 @@ func TestSMembers(t *testing.T) {
 	}
 }
 
+func TestSRem(t *testing.T) {
+	rc.Del("myset")
+	defer rc.Del("myset")
+	rc.SAdd("myset", "one", "two", "three")
+	if n, err := rc.SRem("myset", "one", "two", "four"); err != nil {
+		t.Fatal(err)
+	} else if n != 2 {
+		t.Fatalf(errUnexpected, n)
+	}
+	if ok, err := rc.SIsMember("myset", "three"); err != nil {
+		t.Fatal(err)
+	} else if !ok {
+		t.Fatalf(errUnexpected, ok)
+	}
+	if v, err := rc.SMIsMember("myset", "one", "three"); err != nil {
+		t.Fatal(err)
+	} else if !reflect.DeepEqual(v, []bool{false, true}) {
+		t.Fatalf(errUnexpected, v)
+	}
+}
+
+func TestSPop(t *testing.T) {
+	rc.Del("myset")
+	defer rc.Del("myset")
+	rc.SAdd("myset", "one", "two", "three")
+	if v, err := rc.SRandMemberCount("myset", 5); err != nil {
+		t.Fatal(err)
+	} else if len(v) != 3 {
+		t.Fatalf(errUnexpected, v)
+	}
+	if v, err := rc.SRandMemberCount("myset", -5); err != nil {
+		t.Fatal(err)
+	} else if len(v) != 5 {
+		t.Fatalf(errUnexpected, v)
+	}
+	if v, err := rc.SPopCount("myset", 2); err != nil {
+		t.Fatal(err)
+	} else if len(v) != 2 {
+		t.Fatalf(errUnexpected, v)
+	}
+	if v, err := rc.SPop("myset"); err != nil {
+		t.Fatal(err)
+	} else if v == "" {
+		t.Fatalf(errUnexpected, v)
+	}
+	if v, err := rc.SPop("myset"); err != nil {
+		t.Fatal(err)
+	} else if v != "" {
+		t.Fatalf(errUnexpected, v)
+	}
+}
+
+// testSetOps checks SInter, SUnion and SDiff of k1, k2 and k3.
+func testSetOps(t *testing.T, c *Client, k1, k2, k3 string) {
+	c.Del(k1, k2, k3)
+	defer c.Del(k1, k2, k3)
+	c.SAdd(k1, "a", "b", "c", "d")
+	c.SAdd(k2, "c")
+	c.SAdd(k3, "a", "c", "e")
+	sorted := func(v []string, err error) []string {
+		if err != nil {
+			t.Fatal(err)
+		}
+		sort.Strings(v)
+		return v
+	}
+	if v := sorted(c.SInter(k1, k2, k3)); !reflect.DeepEqual(v, []string{"c"}) {
+		t.Fatalf(errUnexpected, v)
+	}
+	if v := sorted(c.SUnion(k1, k2, k3)); !reflect.DeepEqual(v, []string{"a", "b", "c", "d", "e"}) {
+		t.Fatalf(errUnexpected, v)
+	}
+	if v := sorted(c.SDiff(k1, k2, k3)); !reflect.DeepEqual(v, []string{"b", "d"}) {
+		t.Fatalf(errUnexpected, v)
+	}
+	if v := sorted(c.SInter(k1, "nokey")); len(v) != 0 {
+		t.Fatalf(errUnexpected, v)
+	}
+}
+
+func TestSetOps(t *testing.T) {
+	testSetOps(t, rc, "myset1", "myset2", "myset3")
+}
+
+func TestSetOpsSharded(t *testing.T) {
+	c := New("127.0.0.1:6379", "127.0.0.1:6379 db=1")
+	keys := keysOnDistinctServers(t, c, "myset", 2)
+	other := keysOnDistinctServers(t, c, "otherset", 1)
+	// The first and last keys are on the same server.
+	testSetOps(t, c, keys[0], keys[1], other[0])
+	if _, err := c.SInterStore(keys[0], keys[1]); err != ErrCrossShard {
+		t.Fatalf(errUnexpected, err)
+	}
+	if _, err := c.SInterCard(0, keys[0], keys[1]); err != ErrCrossShard {
+		t.Fatalf(errUnexpected, err)
+	}
+}
+
+func TestSetStore(t *testing.T) {
+	k1, k2, dst := "{myset}1", "{myset}2", "{myset}dst"
+	rc.Del(k1, k2, dst)
+	defer rc.Del(k1, k2, dst)
+	rc.SAdd(k1, "a", "b", "c")
+	rc.SAdd(k2, "c", "d")
+	if n, err := rc.SUnionStore(dst, k1, k2); err != nil {
+		t.Fatal(err)
+	} else if n != 4 {
+		t.Fatalf(errUnexpected, n)
+	}
+	if n, err := rc.SInterStore(dst, k1, k2); err != nil {
+		t.Fatal(err)
+	} else if n != 1 {
+		t.Fatalf(errUnexpected, n)
+	}
+	if n, err := rc.SDiffStore(dst, k1, k2); err != nil {
+		t.Fatal(err)
+	} else if n != 2 {
+		t.Fatalf(errUnexpected, n)
+	}
+	if n, err := rc.SInterCard(0, k1, k2); err != nil {
+		t.Fatal(err)
+	} else if n != 1 {
+		t.Fatalf(errUnexpected, n)
+	}
+	if n, err := rc.SInterCard(1, k1, k1); err != nil {
+		t.Fatal(err)
+	} else if n != 1 {
+		t.Fatalf(errUnexpected, n)
+	}
+}
+
 // TestSetAndGet sets a key, fetches it, and compare the results.
 func _TestSetAndGet(t *testing.T) {
 	k := randomString(1024)
 	v := randomString(16 * 1024 * 1024)
 	defer rc.Del(k)
-	if err := rc.Set(k, v); err != nil {
+	if _, _, err := rc.Set(k, v, SetArgs{}); err != nil {
 		t.Fatal(err)
 	}
 	if val, err := rc.Get(k); err != nil {
@@ -734,6 +1581,145 @@ func TestSetEx(t *testing.T) {
 	}
 }
 
+func TestSetArgs(t *testing.T) {
+	k := randomString(16)
+	defer rc.Del(k)
+	if ok, _, err := rc.Set(k, "a", SetArgs{XX: true}); err != nil {
+		t.Fatal(err)
+	} else if ok {
+		t.Fatalf(errUnexpected, ok)
+	}
+	if ok, old, err := rc.Set(k, "a", SetArgs{NX: true, Get: true, EX: 10}); err != nil {
+		t.Fatal(err)
+	} else if !ok || old != nil {
+		t.Fatalf(errUnexpected, old)
+	}
+	if ok, old, err := rc.Set(k, "b", SetArgs{NX: true, Get: true}); err != nil {
+		t.Fatal(err)
+	} else if ok || old == nil || *old != "a" {
+		t.Fatalf(errUnexpected, old)
+	}
+	if ok, old, err := rc.Set(k, "c", SetArgs{XX: true, Get: true, KeepTTL: true}); err != nil {
+		t.Fatal(err)
+	} else if !ok || old == nil || *old != "a" {
+		t.Fatalf(errUnexpected, old)
+	}
+	if n, err := rc.TTL(k); err != nil {
+		t.Fatal(err)
+	} else if n <= 0 {
+		t.Fatalf(errUnexpected, n)
+	}
+	if ok, _, err := rc.Set(k, "", SetArgs{PX: 10000}); err != nil {
+		t.Fatal(err)
+	} else if !ok {
+		t.Fatalf(errUnexpected, ok)
+	}
+	// An empty old value is not a missing key.
+	if ok, old, err := rc.Set(k, "d", SetArgs{Get: true}); err != nil {
+		t.Fatal(err)
+	} else if !ok || old == nil || *old != "" {
+		t.Fatalf(errUnexpected, old)
+	}
+}
+
+func TestSetNX(t *testing.T) {
+	k := randomString(16)
+	defer rc.Del(k)
+	if ok, err := rc.SetNX(k, "a"); err != nil {
+		t.Fatal(err)
+	} else if !ok {
+		t.Fatalf(errUnexpected, ok)
+	}
+	if ok, err := rc.SetNX(k, "b"); err != nil {
+		t.Fatal(err)
+	} else if ok {
+		t.Fatalf(errUnexpected, ok)
+	}
+	if err := rc.SetWithNx(k, "b"); err != nil {
+		t.Fatal(err)
+	}
+	if v, _ := rc.Get(k); v != "a" {
+		t.Fatalf(errUnexpected, v)
+	}
+}
+
+func TestSetRange(t *testing.T) {
+	k := randomString(16)
+	defer rc.Del(k)
+	rc.Set(k, "Hello World", SetArgs{})
+	if n, err := rc.SetRange(k, 6, "Redis"); err != nil {
+		t.Fatal(err)
+	} else if n != 11 {
+		t.Fatalf(errUnexpected, n)
+	}
+	if n, err := rc.StrLen(k); err != nil {
+		t.Fatal(err)
+	} else if n != 11 {
+		t.Fatalf(errUnexpected, n)
+	}
+	if v, _ := rc.Get(k); v != "Hello Redis" {
+		t.Fatalf(errUnexpected, v)
+	}
+}
+
+func TestGetDelEx(t *testing.T) {
+	k := randomString(16)
+	defer rc.Del(k)
+	if ok, err := rc.PSetEx(k, 10000, "a"); err != nil {
+		t.Fatal(err)
+	} else if !ok {
+		t.Fatalf(errUnexpected, ok)
+	}
+	if v, err := rc.GetEx(k, GetExArgs{Persist: true}); err != nil {
+		t.Fatal(err)
+	} else if v != "a" {
+		t.Fatalf(errUnexpected, v)
+	}
+	if n, _ := rc.TTL(k); n != -1 {
+		t.Fatalf(errUnexpected, n)
+	}
+	if v, err := rc.GetDel(k); err != nil {
+		t.Fatal(err)
+	} else if v != "a" {
+		t.Fatalf(errUnexpected, v)
+	}
+	if v, err := rc.GetDel(k); err != nil {
+		t.Fatal(err)
+	} else if v != "" {
+		t.Fatalf(errUnexpected, v)
+	}
+}
+
+func TestIncrByFloat(t *testing.T) {
+	k := randomString(16)
+	defer rc.Del(k)
+	rc.Set(k, "10.50", SetArgs{})
+	if v, err := rc.IncrByFloat(k, 0.1); err != nil {
+		t.Fatal(err)
+	} else if v != 10.6 {
+		t.Fatalf(errUnexpected, v)
+	}
+}
+
+func TestMSetNX(t *testing.T) {
+	k1, k2 := "{msetnx}1", "{msetnx}2"
+	rc.Del(k1, k2)
+	defer rc.Del(k1, k2)
+	if ok, err := rc.MSetNX(map[string]string{k1: "a"}); err != nil {
+		t.Fatal(err)
+	} else if !ok {
+		t.Fatalf(errUnexpected, ok)
+	}
+	if ok, err := rc.MSetNX(map[string]string{k1: "b", k2: "b"}); err != nil {
+		t.Fatal(err)
+	} else if ok {
+		t.Fatalf(errUnexpected, ok)
+	}
+	if v, _ := rc.MGet(k1, k2); v[0] != "a" || v[1] != "" {
+		t.Fatalf(errUnexpected, v)
+	}
+}
+
 func TestPing(t *testing.T) {
 	if err := rc.Ping(); err != nil {
 		t.Fatal(err)
@@ -744,7 +1730,7 @@ func TestPing(t *testing.T) {
 func TestHExists(t *testing.T) {
 	rc.Del("key1")
 	defer rc.Del("key1")
-	rc.HSet("key1", "Hello")
+	rc.HSet("key1", "Hello", "World")
 	if ok, err := rc.HExists("key1","Hello"); err != nil {
 		t.Fatal(err)
 	} else if !ok {
@@ -812,7 +1798,7 @@ func TestHMGet(t *testing.T) {
 	})
 	if v, err := rc.HMGet("mykey", "foo", "hello"); err != nil {
 		t.Fatalf(errUnexpected, err)
-	} else if len(v) != 2 || v[0] != "bar" || v[1] != "world" {
+	} else if len(v) != 2 || v[0] == nil || *v[0] != "bar" || v[1] == nil || *v[1] != "world" {
 		t.Fatalf(errUnexpected, v)
 	}
 }
@@ -832,13 +1818,206 @@ func TestHGetAll(t *testing.T) {
 	}
 }
 
-// TestZIncrBy increments a field in a hash and checks the result.
+func TestHDel(t *testing.T) {
+	rc.Del("mykey")
+	defer rc.Del("mykey")
+	rc.HMSet("mykey", map[string]string{"a": "1", "b": "2", "c": "3"})
+	if n, err := rc.HDel("mykey", "a", "b", "d"); err != nil {
+		t.Fatal(err)
+	} else if n != 2 {
+		t.Fatalf(errUnexpected, n)
+	}
+	if n, err := rc.HLen("mykey"); err != nil {
+		t.Fatal(err)
+	} else if n != 1 {
+		t.Fatalf(errUnexpected, n)
+	}
+}
+
+func TestHKeysVals(t *testing.T) {
+	rc.Del("mykey")
+	defer rc.Del("mykey")
+	rc.HMSet("mykey", map[string]string{"a": "1", "b": "2"})
+	if v, err := rc.HKeys("mykey"); err != nil {
+		t.Fatal(err)
+	} else if len(v) != 2 || v[0]+v[1] != "ab" && v[0]+v[1] != "ba" {
+		t.Fatalf(errUnexpected, v)
+	}
+	if v, err := rc.HVals("mykey"); err != nil {
+		t.Fatal(err)
+	} else if len(v) != 2 || v[0]+v[1] != "12" && v[0]+v[1] != "21" {
+		t.Fatalf(errUnexpected, v)
+	}
+	if v, err := rc.HKeys("nokey"); err != nil {
+		t.Fatal(err)
+	} else if len(v) != 0 {
+		t.Fatalf(errUnexpected, v)
+	}
+}
+
+func TestHSetNX(t *testing.T) {
+	rc.Del("mykey")
+	defer rc.Del("mykey")
+	if ok, err := rc.HSetNX("mykey", "foo", "bar"); err != nil {
+		t.Fatal(err)
+	} else if !ok {
+		t.Fatalf(errUnexpected, ok)
+	}
+	if ok, err := rc.HSetNX("mykey", "foo", "baz"); err != nil {
+		t.Fatal(err)
+	} else if ok {
+		t.Fatalf(errUnexpected, ok)
+	}
+	if n, err := rc.HStrLen("mykey", "foo"); err != nil {
+		t.Fatal(err)
+	} else if n != 3 {
+		t.Fatalf(errUnexpected, n)
+	}
+	if n, err := rc.HStrLen("mykey", "nofield"); err != nil {
+		t.Fatal(err)
+	} else if n != 0 {
+		t.Fatalf(errUnexpected, n)
+	}
+}
+
+func TestHIncrByFloat(t *testing.T) {
+	rc.Del("mykey")
+	defer rc.Del("mykey")
+	if n, err := rc.HIncrByFloat("mykey", "beavis", 1.5); err != nil {
+		t.Fatal(err)
+	} else if n != 1.5 {
+		t.Fatalf(errUnexpected, n)
+	}
+	if n, err := rc.HIncrByFloat("mykey", "beavis", -0.25); err != nil {
+		t.Fatal(err)
+	} else if n != 1.25 {
+		t.Fatalf(errUnexpected, n)
+	}
+}
+
+func TestHRandField(t *testing.T) {
+	rc.Del("mykey")
+	defer rc.Del("mykey")
+	rc.HMSet("mykey", map[string]string{"a": "1", "b": "2"})
+	if v, err := rc.HRandField("mykey"); err != nil {
+		t.Fatal(err)
+	} else if v != "a" && v != "b" {
+		t.Fatalf(errUnexpected, v)
+	}
+	if _, err := rc.HRandField("nokey"); err != ErrNil {
+		t.Fatalf(errUnexpected, err)
+	}
+	if v, err := rc.HRandFieldCount("mykey", 5); err != nil {
+		t.Fatal(err)
+	} else if len(v) != 2 {
+		t.Fatalf(errUnexpected, v)
+	}
+	if v, err := rc.HRandFieldCount("mykey", -5); err != nil {
+		t.Fatal(err)
+	} else if len(v) != 5 {
+		t.Fatalf(errUnexpected, v)
+	}
+	if v, err := rc.HRandFieldCount("nokey", 1); err != nil {
+		t.Fatal(err)
+	} else if len(v) != 0 {
+		t.Fatalf(errUnexpected, v)
+	}
+}
+
+func TestHExpire(t *testing.T) {
+	rc.Del("mykey")
+	defer rc.Del("mykey")
+	rc.HMSet("mykey", map[string]string{"a": "1", "b": "2"})
+	if v, err := rc.HExpire("mykey", 100, "a", "c"); err != nil {
+		t.Fatal(err)
+	} else if len(v) != 2 || v[0] != 1 || v[1] != -2 {
+		t.Fatalf(errUnexpected, v)
+	}
+	if v, err := rc.HTTL("mykey", "a", "b", "c"); err != nil {
+		t.Fatal(err)
+	} else if len(v) != 3 || v[0] <= 0 || v[1] != -1 || v[2] != -2 {
+		t.Fatalf(errUnexpected, v)
+	}
+	if v, err := rc.HPersist("mykey", "a", "b"); err != nil {
+		t.Fatal(err)
+	} else if len(v) != 2 || v[0] != 1 || v[1] != -1 {
+		t.Fatalf(errUnexpected, v)
+	}
+	if v, err := rc.HTTL("nokey", "a"); err != nil {
+		t.Fatal(err)
+	} else if len(v) != 1 || v[0] != -2 {
+		t.Fatalf(errUnexpected, v)
+	}
+}
+
+func TestNilReply(t *testing.T) {
+	rc.Del("mykey")
+	if v, err := rc.Get("mykey"); err != nil {
+		t.Fatal(err)
+	} else if v != "" {
+		t.Fatalf(errUnexpected, v)
+	}
+	rc.HSet("mykey", "foo", "")
+	defer rc.Del("mykey")
+	if v, err := rc.HMGet("mykey", "nofield", "foo"); err != nil {
+		t.Fatal(err)
+	} else if len(v) != 2 || v[0] != nil || v[1] == nil || *v[1] != "" {
+		t.Fatalf(errUnexpected, v)
+	}
+	if v, err := rc.HGet("mykey", "foo"); err != nil {
+		t.Fatal(err)
+	} else if v != "" {
+		t.Fatalf(errUnexpected, v)
+	}
+	if _, err := rc.HGet("mykey", "nofield"); err != ErrNil {
+		t.Fatalf(errUnexpected, err)
+	}
+}
+
+func TestIface2Score(t *testing.T) {
+	if _, err := iface2score(nil); err != ErrNil {
+		t.Fatalf(errUnexpected, err)
+	}
+	if _, err := iface2score(""); err == nil || err == ErrNil {
+		t.Fatalf(errUnexpected, err)
+	}
+	if f, err := iface2score("1.5"); err != nil {
+		t.Fatal(err)
+	} else if f != 1.5 {
+		t.Fatalf(errUnexpected, f)
+	}
+}
+
+// TestParseNilBulk checks that nil bulk replies are parsed as nil, so they
+// can be told apart from empty strings, at the top level and in arrays.
+func TestParseNilBulk(t *testing.T) {
+	tests := map[string]interface{}{
+		"$-1\r\n":                    nil,
+		"$0\r\n\r\n":                 "",
+		"*2\r\n$-1\r\n$3\r\nbar\r\n": []interface{}{nil, "bar"},
+	}
+	for resp, want := range tests {
+		v, err := rc.parseResponse(bufio.NewReader(strings.NewReader(resp)))
+		if err != nil {
+			t.Fatal(err)
+		} else if !reflect.DeepEqual(v, want) {
+			t.Fatalf(errUnexpected, v)
+		}
+	}
+}
+
+// TestZIncrBy increments a member of a sorted set and checks the result.
 func TestZIncrBy(t *testing.T) {
 	rc.Del("mykey")
 	defer rc.Del("mykey")
 	if n, err := rc.ZIncrBy("mykey", 5, "beavis"); err != nil {
 		t.Fatalf(errUnexpected, err)
-	} else if n != "5" {
+	} else if n != 5 {
+		t.Fatalf(errUnexpected, n)
+	}
+	if n, err := rc.ZIncrBy("mykey", 0.5, "beavis"); err != nil {
+		t.Fatalf(errUnexpected, err)
+	} else if n != 5.5 {
 		t.Fatalf(errUnexpected, n)
 	}
 }
@@ -852,30 +2031,74 @@ func TestZScore(t *testing.T) {
 	}
 	if n, err := rc.ZScore("mykey", "beavis"); err != nil {
 		t.Fatalf(errUnexpected, err)
-	} else if n != "5" {
+	} else if n != 5 {
 		t.Fatalf(errUnexpected, n)
 	}
+	if _, err := rc.ZScore("mykey", "butthead"); err != ErrNil {
+		t.Fatalf(errUnexpected, err)
+	}
+}
+
+func TestZMScore(t *testing.T) {
+	rc.Del("myzset")
+	defer rc.Del("myzset")
+	rc.ZAdd("myzset", Z{"beavis", 1}, Z{"butthead", 2.5})
+	v, err := rc.ZMScore("myzset", "butthead", "professor_buzzcut", "beavis")
+	if err != nil {
+		t.Fatal(err)
+	} else if len(v) != 3 || v[0] == nil || *v[0] != 2.5 || v[1] != nil || v[2] == nil || *v[2] != 1 {
+		t.Fatalf(errUnexpected, v)
+	}
 }
 
 // Test ZAdd
 func TestZAdd(t *testing.T) {
 	rc.Del("myzset")
 	defer rc.Del("myzset")
-	if _, err := rc.ZAdd("myzset", 1, "beavis"); err != nil {
+	if _, err := rc.ZAdd("myzset", Z{"beavis", 1}); err != nil {
 		t.Fatalf(errUnexpected, err)
 	}
-	if n, err := rc.ZAdd("myzset", 2, "butthead", 3, "professor_buzzcut"); err != nil {
+	if n, err := rc.ZAdd("myzset", Z{"butthead", 2}, Z{"professor_buzzcut", 3}); err != nil {
 		t.Fatalf(errUnexpected, err)
 	} else if n != 2 {
 		t.Fatalf(errUnexpected, n)
 	}
 }
 
+func TestZAddArgs(t *testing.T) {
+	rc.Del("myzset")
+	defer rc.Del("myzset")
+	rc.ZAdd("myzset", Z{"beavis", 1})
+	if n, err := rc.ZAddArgs("myzset", ZAddArgs{NX: true}, Z{"beavis", 5}, Z{"butthead", 2}); err != nil {
+		t.Fatal(err)
+	} else if n != 1 {
+		t.Fatalf(errUnexpected, n)
+	}
+	if n, err := rc.ZAddArgs("myzset", ZAddArgs{XX: true, CH: true}, Z{"beavis", 5}, Z{"daria", 2}); err != nil {
+		t.Fatal(err)
+	} else if n != 1 {
+		t.Fatalf(errUnexpected, n)
+	}
+	if n, err := rc.ZAddArgs("myzset", ZAddArgs{GT: true, CH: true}, Z{"beavis", 4}); err != nil {
+		t.Fatal(err)
+	} else if n != 0 {
+		t.Fatalf(errUnexpected, n)
+	}
+	if n, err := rc.ZAddIncr("myzset", ZAddArgs{}, Z{"beavis", 1.5}); err != nil {
+		t.Fatal(err)
+	} else if n != 6.5 {
+		t.Fatalf(errUnexpected, n)
+	}
+	if _, err := rc.ZAddIncr("myzset", ZAddArgs{NX: true}, Z{"beavis", 1}); err != ErrNil {
+		t.Fatalf(errUnexpected, err)
+	}
+}
+
 // Test ZRem
 func TestZRem(t *testing.T) {
 	rc.Del("myzset")
 	defer rc.Del("myzset")
-	if _, err := rc.ZAdd("myzset", 1, "beavis", 2, "butthead", 3, "professor_buzzcut"); err != nil {
+	if _, err := rc.ZAdd("myzset", Z{"beavis", 1}, Z{"butthead", 2}, Z{"professor_buzzcut", 3}); err != nil {
 		t.Fatalf(errUnexpected, err)
 	}
 	if n, err := rc.ZRem("myzset", "beavis", "butthead", "professor_buzzcut"); err != nil {
@@ -889,21 +2112,19 @@ func TestZRem(t *testing.T) {
 func TestZRange(t *testing.T) {
 	rc.Del("myzset")
 	defer rc.Del("myzset")
-	if _, err := rc.ZAdd("myzset", 1, "beavis", 2, "butthead", 3, "professor_buzzcut"); err != nil {
+	if _, err := rc.ZAdd("myzset", Z{"beavis", 1}, Z{"butthead", 2}, Z{"professor_buzzcut", 3}); err != nil {
 		t.Fatalf(errUnexpected, err)
 	}
 
-	if n, err := rc.ZRange("myzset", 0, 1, false); err != nil {
+	if n, err := rc.ZRange("myzset", 0, 1); err != nil {
 		t.Fatalf(errUnexpected, err)
-	} else if len(n) != 2 {
+	} else if len(n) != 2 || n[0] != "beavis" {
 		t.Fatalf(errUnexpected, n)
 	}
 
-	if n, err := rc.ZRange("myzset", 0, 1, true); err != nil {
+	if n, err := rc.ZRangeWithScores("myzset", 0, 1); err != nil {
 		t.Fatalf(errUnexpected, err)
-	} else if len(n) != 4 {
-		t.Fatalf(errUnexpected, n)
-	} else if n[0] != "beavis" && n[2] != "butthead" {
+	} else if len(n) != 2 || n[0] != (Z{"beavis", 1}) || n[1] != (Z{"butthead", 2}) {
 		t.Fatalf(errUnexpected, n)
 	}
 }
@@ -912,21 +2133,148 @@ func TestZRange(t *testing.T) {
 func TestZRevRange(t *testing.T) {
 	rc.Del("myzset")
 	defer rc.Del("myzset")
-	if _, err := rc.ZAdd("myzset", 1, "beavis", 2, "butthead", 3, "professor_buzzcut"); err != nil {
+	if _, err := rc.ZAdd("myzset", Z{"beavis", 1}, Z{"butthead", 2}, Z{"professor_buzzcut", 3}); err != nil {
 		t.Fatalf(errUnexpected, err)
 	}
 
-	if n, err := rc.ZRange("myzset", 0, 1, false); err != nil {
+	if n, err := rc.ZRevRange("myzset", 0, 1); err != nil {
 		t.Fatalf(errUnexpected, err)
-	} else if len(n) != 2 {
+	} else if len(n) != 2 || n[0] != "professor_buzzcut" {
+		t.Fatalf(errUnexpected, n)
+	}
+
+	if n, err := rc.ZRevRangeWithScores("myzset", 0, 1); err != nil {
+		t.Fatalf(errUnexpected, err)
+	} else if len(n) != 2 || n[0] != (Z{"professor_buzzcut", 3}) || n[1] != (Z{"butthead", 2}) {
+		t.Fatalf(errUnexpected, n)
+	}
+}
+
+func TestZRangeByScore(t *testing.T) {
+	rc.Del("myzset")
+	defer rc.Del("myzset")
+	rc.ZAdd("myzset", Z{"a", 1}, Z{"b", 2}, Z{"c", 3}, Z{"d", 4})
+	tests := []struct {
+		min, max      string
+		offset, count int
+		want          []string
+	}{
+		{"-inf", "+inf", 0, 0, []string{"a", "b", "c", "d"}},
+		{"(1", "3", 0, 0, []string{"b", "c"}},
+		{"-inf", "+inf", 1, 2, []string{"b", "c"}},
+		{"-inf", "+inf", 2, 0, []string{"c", "d"}},
+		{"5", "+inf", 0, 0, []string{}},
+	}
+	for _, test := range tests {
+		v, err := rc.ZRangeByScore("myzset", test.min, test.max, test.offset, test.count)
+		if err != nil {
+			t.Fatal(err)
+		} else if strings.Join(v, " ") != strings.Join(test.want, " ") {
+			t.Fatalf(errUnexpected, v)
+		}
+	}
+	if v, err := rc.ZRevRangeByScore("myzset", "(4", "-inf", 0, 2); err != nil {
+		t.Fatal(err)
+	} else if strings.Join(v, " ") != "c b" {
+		t.Fatalf(errUnexpected, v)
+	}
+	if v, err := rc.ZRangeByScoreWithScores("myzset", "3", "+inf", 0, 0); err != nil {
+		t.Fatal(err)
+	} else if len(v) != 2 || v[0] != (Z{"c", 3}) {
+		t.Fatalf(errUnexpected, v)
+	}
+	if v, err := rc.ZRevRangeByScoreWithScores("myzset", "+inf", "-inf", 0, 1); err != nil {
+		t.Fatal(err)
+	} else if len(v) != 1 || v[0] != (Z{"d", 4}) {
+		t.Fatalf(errUnexpected, v)
+	}
+}
+
+func TestZRangeByLex(t *testing.T) {
+	rc.Del("myzset")
+	defer rc.Del("myzset")
+	rc.ZAdd("myzset", Z{"a", 0}, Z{"b", 0}, Z{"c", 0}, Z{"d", 0})
+	if v, err := rc.ZRangeByLex("myzset", "(a", "[c", 0, 0); err != nil {
+		t.Fatal(err)
+	} else if strings.Join(v, " ") != "b c" {
+		t.Fatalf(errUnexpected, v)
+	}
+	if v, err := rc.ZRevRangeByLex("myzset", "+", "-", 1, 2); err != nil {
+		t.Fatal(err)
+	} else if strings.Join(v, " ") != "c b" {
+		t.Fatalf(errUnexpected, v)
+	}
+}
+
+func TestZRemRange(t *testing.T) {
+	rc.Del("myzset")
+	defer rc.Del("myzset")
+	rc.ZAdd("myzset", Z{"a", 1}, Z{"b", 2}, Z{"c", 3}, Z{"d", 4})
+	if n, err := rc.ZRemRangeByRank("myzset", 0, 1); err != nil {
+		t.Fatal(err)
+	} else if n != 2 {
+		t.Fatalf(errUnexpected, n)
+	}
+	if n, err := rc.ZRemRangeByScore("myzset", "(3", "+inf"); err != nil {
+		t.Fatal(err)
+	} else if n != 1 {
 		t.Fatalf(errUnexpected, n)
 	}
+	if v, err := rc.ZRange("myzset", 0, -1); err != nil {
+		t.Fatal(err)
+	} else if len(v) != 1 || v[0] != "c" {
+		t.Fatalf(errUnexpected, v)
+	}
+}
 
-	if n, err := rc.ZRange("myzset", 0, 1, true); err != nil {
+func TestZPop(t *testing.T) {
+	rc.Del("myzset")
+	defer rc.Del("myzset")
+	rc.ZAdd("myzset", Z{"a", 1}, Z{"b", 2}, Z{"c", 3}, Z{"d", 4})
+	if v, err := rc.ZPopMin("myzset", 0); err != nil {
+		t.Fatal(err)
+	} else if len(v) != 1 || v[0] != (Z{"a", 1}) {
+		t.Fatalf(errUnexpected, v)
+	}
+	if v, err := rc.ZPopMax("myzset", 2); err != nil {
+		t.Fatal(err)
+	} else if len(v) != 2 || v[0] != (Z{"d", 4}) || v[1] != (Z{"c", 3}) {
+		t.Fatalf(errUnexpected, v)
+	}
+	if k, z, err := rc.BZPopMin(1, "myzset"); err != nil {
+		t.Fatal(err)
+	} else if k != "myzset" || z != (Z{"b", 2}) {
+		t.Fatalf(errUnexpected, z)
+	}
+	if _, _, err := rc.BZPopMax(1, "myzset"); err != ErrTimedOut {
 		t.Fatalf(errUnexpected, err)
-	} else if len(n) != 4 {
+	}
+}
+
+func TestZStore(t *testing.T) {
+	k1, k2, dst := "{myzset}1", "{myzset}2", "{myzset}dst"
+	rc.Del(k1, k2, dst)
+	defer rc.Del(k1, k2, dst)
+	rc.ZAdd(k1, Z{"a", 1}, Z{"b", 2})
+	rc.ZAdd(k2, Z{"b", 3}, Z{"c", 4})
+	if n, err := rc.ZUnionStore(dst, ZStore{Keys: []string{k1, k2}, Weights: []float64{2, 1}}); err != nil {
+		t.Fatal(err)
+	} else if n != 3 {
+		t.Fatalf(errUnexpected, n)
+	}
+	if v, err := rc.ZRangeWithScores(dst, 0, -1); err != nil {
+		t.Fatal(err)
+	} else if len(v) != 3 || v[0] != (Z{"a", 2}) || v[1] != (Z{"c", 4}) || v[2] != (Z{"b", 7}) {
+		t.Fatalf(errUnexpected, v)
+	}
+	if n, err := rc.ZInterStore(dst, ZStore{Keys: []string{k1, k2}, Aggregate: "MAX"}); err != nil {
+		t.Fatal(err)
+	} else if n != 1 {
 		t.Fatalf(errUnexpected, n)
-	} else if n[0] != "professor_buzzcut" && n[2] != "butthead" {
+	}
+	if n, err := rc.ZScore(dst, "b"); err != nil {
+		t.Fatal(err)
+	} else if n != 3 {
 		t.Fatalf(errUnexpected, n)
 	}
 }
@@ -935,7 +2283,7 @@ func TestZRevRange(t *testing.T) {
 func TestZCard(t *testing.T) {
 	rc.Del("myzset")
 	defer rc.Del("myzset")
-	if _, err := rc.ZAdd("myzset", 1, "beavis", 2, "butthead", 3, "professor_buzzcut"); err != nil {
+	if _, err := rc.ZAdd("myzset", Z{"beavis", 1}, Z{"butthead", 2}, Z{"professor_buzzcut", 3}); err != nil {
 		t.Fatalf(errUnexpected, err)
 	}
 
@@ -950,21 +2298,74 @@ func TestZCard(t *testing.T) {
 func TestZCount(t *testing.T) {
 	rc.Del("myzset")
 	defer rc.Del("myzset")
-	if _, err := rc.ZAdd("myzset", 1, "beavis", 2, "butthead", 3, "professor_buzzcut"); err != nil {
+	if _, err := rc.ZAdd("myzset", Z{"beavis", 1}, Z{"butthead", 2}, Z{"professor_buzzcut", 3}); err != nil {
 		t.Fatalf(errUnexpected, err)
 	}
 
-	if n, err := rc.ZCount("myzset", 0, 2); err != nil {
+	if n, err := rc.ZCount("myzset", "0", "2"); err != nil {
 		t.Fatalf(errUnexpected, err)
 	} else if n != 2 {
 		t.Fatalf(errUnexpected, n)
 	}
+	if n, err := rc.ZCount("myzset", "(1", "+inf"); err != nil {
+		t.Fatalf(errUnexpected, err)
+	} else if n != 2 {
+		t.Fatalf(errUnexpected, n)
+	}
+}
+
+func TestSScan(t *testing.T) {
+	rc.Del("myset")
+	defer rc.Del("myset")
+	rc.SAdd("myset", "one", "two", "three", "four")
+	if _, members, err := rc.SScan("myset", 0, "t*", 100); err != nil {
+		t.Fatal(err)
+	} else if len(members) != 2 {
+		t.Fatalf(errUnexpected, members)
+	}
+	if members, err := rc.SScanAll("myset", "", 1); err != nil {
+		t.Fatal(err)
+	} else if len(members) < 4 {
+		t.Fatalf(errUnexpected, members)
+	}
+}
+
+func TestHScan(t *testing.T) {
+	rc.Del("myhash")
+	defer rc.Del("myhash")
+	rc.HMSet("myhash", map[string]string{"field1": "Hello", "field2": "World"})
+	if next, fields, err := rc.HScan("myhash", 0, "", 0); err != nil {
+		t.Fatal(err)
+	} else if next != 0 || fields["field2"] != "World" {
+		t.Fatalf(errUnexpected, fields)
+	}
+	if fields, err := rc.HScanAll("myhash", "*1", 1); err != nil {
+		t.Fatal(err)
+	} else if len(fields) != 1 || fields["field1"] != "Hello" {
+		t.Fatalf(errUnexpected, fields)
+	}
+}
+
+func TestZScan(t *testing.T) {
+	rc.Del("myzset")
+	defer rc.Del("myzset")
+	rc.ZAdd("myzset", Z{"one", 1}, Z{"two", 2})
+	if _, members, err := rc.ZScan("myzset", 0, "t*", 0); err != nil {
+		t.Fatal(err)
+	} else if len(members) != 1 || members[0] != (Z{"two", 2}) {
+		t.Fatalf(errUnexpected, members)
+	}
+	if members, err := rc.ZScanAll("myzset", "", 1); err != nil {
+		t.Fatal(err)
+	} else if len(members) != 2 {
+		t.Fatalf(errUnexpected, members)
+	}
 }
 
 // Benchmark plain Set
 func BenchmarkSet(b *testing.B) {
 	for i := 0; i < b.N; i++ {
-		if err := rc.Set("foo", "bar"); err != nil {
+		if _, _, err := rc.Set("foo", "bar", SetArgs{}); err != nil {
 			b.Fatal(err)
 		}
 	}
@@ -973,7 +2374,7 @@ func BenchmarkSet(b *testing.B) {
 // Benchmark plain Get
 func BenchmarkGet(b *testing.B) {
 	defer rc.Del("foo")
-	rc.Set("foo", "bar")
+	rc.Set("foo", "bar", SetArgs{})
 	for i := 0; i < b.N; i++ {
 		if v, err := rc.Get("foo"); err != nil {
 			b.Fatal(err)
@@ -986,7 +2387,7 @@ func BenchmarkGet(b *testing.B) {
 // Test/Benchmark INCRBY
 func BenchmarkIncrBy(b *testing.B) {
 	rc.Del("foo")
-	if err := rc.Set("foo", "0"); err != nil {
+	if _, _, err := rc.Set("foo", "0", SetArgs{}); err != nil {
 		b.Fatal(err)
 	}
 	for i := 0; i < b.N; i++ {
@@ -1004,7 +2405,7 @@ func BenchmarkIncrBy(b *testing.B) {
 // Benchmark DECR
 func BenchmarkDecrBy(b *testing.B) {
 	defer rc.Del("foo")
-	if err := rc.Set("foo", strconv.Itoa(b.N)); err != nil {
+	if _, _, err := rc.Set("foo", strconv.Itoa(b.N), SetArgs{}); err != nil {
 		b.Fatal(err)
 	}
 	for i := 0; i < b.N; i++ {
@@ -0,0 +1,266 @@
+// Copyright 2013-2014 go-redis authors.  All rights reserved.
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+package redis
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Defaults of Consumer.
+const (
+	DefaultConsumerCount = 10
+	DefaultConsumerBlock = time.Second
+)
+
+// ErrConsumerStarted is returned by Consumer.Start if called twice.
+var ErrConsumerStarted = errors.New("consumer already started")
+
+// Consumer processes the entries of a stream with a pool of workers, which
+// are consumers of the same consumer group. Entries are acknowledged when
+// the handler returns nil, otherwise they stay pending and are delivered
+// again when reclaimed.
+//
+// Example:
+//
+//	cs := rc.NewConsumer("orders", "billing", "host-1",
+//		func(e redis.StreamEntry) error {
+//			return bill(e.Fields)
+//		})
+//	cs.Workers = 8
+//	cs.MinIdle = time.Minute
+//	if err := cs.Start(); err != nil {
+//		...
+//	}
+//	defer cs.Close()
+//
+// Each worker is a consumer named after the Consumer, e.g. "host-1-0".
+// Names should be stable across restarts: workers start by processing
+// the entries left pending for them, before reading new ones.
+type Consumer struct {
+	// Workers is the number of goroutines calling the handler.
+	// If zero, 1 is used.
+	Workers int
+
+	// Count is the maximum number of entries read by a worker at once.
+	// If zero, DefaultConsumerCount is used.
+	Count int
+
+	// Block is how long workers wait for new entries in each read.
+	// Close waits for blocked reads to return. If zero,
+	// DefaultConsumerBlock is used.
+	Block time.Duration
+
+	// MinIdle, if not zero, makes workers reclaim entries that have been
+	// pending for at least MinIdle, e.g. because their consumer died.
+	// It requires redis 6.2 or newer.
+	MinIdle time.Duration
+
+	// ClaimInterval is the interval between reclaims of each worker.
+	// If zero, MinIdle is used.
+	ClaimInterval time.Duration
+
+	// StartID is the ID the consumer group starts reading the stream
+	// after, when the group is created by Start. If empty, "0" is used,
+	// which includes all entries of the stream.
+	StartID string
+
+	// ErrorHandler, if not nil, is called with the errors of redis and of
+	// the handler. Workers retry after errors of redis with a backoff.
+	ErrorHandler func(err error)
+
+	c       *Client
+	stream  string
+	group   string
+	name    string
+	handler func(StreamEntry) error
+
+	lk      sync.Mutex
+	started bool
+	done    chan bool
+	wg      sync.WaitGroup
+}
+
+// NewConsumer returns a Consumer of the given stream and consumer group.
+// Options must be set before calling Start.
+func (c *Client) NewConsumer(stream, group, name string, handler func(StreamEntry) error) *Consumer {
+	return &Consumer{
+		c:       c,
+		stream:  stream,
+		group:   group,
+		name:    name,
+		handler: handler,
+		done:    make(chan bool),
+	}
+}
+
+// Start creates the consumer group if it doesn't exist, and starts the
+// workers.
+func (cs *Consumer) Start() error {
+	cs.lk.Lock()
+	defer cs.lk.Unlock()
+	if cs.started {
+		return ErrConsumerStarted
+	}
+	start := cs.StartID
+	if start == "" {
+		start = "0"
+	}
+	err := cs.c.XGroupCreate(cs.stream, cs.group, start, true)
+	if err != nil && !strings.HasPrefix(err.Error(), "BUSYGROUP") {
+		return err
+	}
+	cs.started = true
+	workers := cs.Workers
+	if workers <= 0 {
+		workers = 1
+	}
+	for n := 0; n < workers; n++ {
+		cs.wg.Add(1)
+		go cs.work(cs.name + "-" + strconv.Itoa(n))
+	}
+	return nil
+}
+
+// Close stops the workers, and waits for them to finish processing the
+// entries they have read.
+func (cs *Consumer) Close() error {
+	cs.lk.Lock()
+	defer cs.lk.Unlock()
+	select {
+	case <-cs.done:
+		return nil
+	default:
+	}
+	close(cs.done)
+	cs.wg.Wait()
+	return nil
+}
+
+func (cs *Consumer) stopped() bool {
+	select {
+	case <-cs.done:
+		return true
+	default:
+		return false
+	}
+}
+
+func (cs *Consumer) fail(err error) {
+	if cs.ErrorHandler != nil {
+		cs.ErrorHandler(err)
+	}
+}
+
+// sleep waits for d, and returns false if the consumer is closed first.
+func (cs *Consumer) sleep(d time.Duration) bool {
+	select {
+	case <-cs.done:
+		return false
+	case <-time.After(d):
+		return true
+	}
+}
+
+// work reads and processes entries as consumer, until closed.
+func (cs *Consumer) work(consumer string) {
+	defer cs.wg.Done()
+	count := cs.Count
+	if count <= 0 {
+		count = DefaultConsumerCount
+	}
+	block := cs.Block
+	if block <= 0 {
+		block = DefaultConsumerBlock
+	}
+	interval := cs.ClaimInterval
+	if interval <= 0 {
+		interval = cs.MinIdle
+	}
+	backoff := DefaultMinBackoff
+	lastClaim := time.Now()
+	claimID := "0-0"
+	// Entries pending for this consumer are read first, starting at 0.
+	id := "0"
+	for !cs.stopped() {
+		var (
+			entries []StreamEntry
+			err     error
+		)
+		claim := cs.MinIdle > 0 && time.Since(lastClaim) >= interval
+		if claim {
+			claimID, entries, err = cs.c.XAutoClaim(cs.stream,
+				cs.group, consumer, cs.MinIdle, claimID, count)
+			if err != nil || claimID == "0-0" {
+				// Scanned all pending entries, or failed to.
+				lastClaim = time.Now()
+				claimID = "0-0"
+			}
+		} else {
+			var r []XStream
+			r, err = cs.c.XReadGroup(cs.group, consumer, count,
+				block, false, cs.stream, id)
+			if err == ErrTimedOut {
+				continue
+			}
+			if err == nil && len(r) > 0 {
+				entries = r[0].Entries
+			}
+		}
+		if err != nil {
+			cs.fail(err)
+			if !cs.sleep(backoff) {
+				return
+			}
+			if backoff *= 2; backoff > DefaultMaxBackoff {
+				backoff = DefaultMaxBackoff
+			}
+			continue
+		}
+		backoff = DefaultMinBackoff
+		if !claim && id != ">" {
+			if len(entries) == 0 {
+				id = ">"
+			} else {
+				id = entries[len(entries)-1].ID
+			}
+		}
+		cs.process(entries)
+	}
+}
+
+// process calls the handler for each entry, and acknowledges the entries
+// it succeeded with. Deleted entries are acknowledged without calling it.
+func (cs *Consumer) process(entries []StreamEntry) {
+	var ack []string
+	for _, e := range entries {
+		if e.Fields != nil {
+			if err := cs.handler(e); err != nil {
+				cs.fail(err)
+				continue
+			}
+		}
+		ack = append(ack, e.ID)
+	}
+	if len(ack) == 0 {
+		return
+	}
+	if _, err := cs.c.XAck(cs.stream, cs.group, ack...); err != nil {
+		cs.fail(err)
+	}
+}
@@ -0,0 +1,243 @@
+// Copyright 2013-2014 go-redis authors.  All rights reserved.
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+package redis
+
+import (
+	"errors"
+	"strings"
+)
+
+// errLibraryName is returned by NewLibrary when the code does not start
+// with the shebang that names the library.
+var errLibraryName = errors.New("library code must start with #!<engine> name=<library>")
+
+// FunctionLibrary is a library of functions, as returned by FunctionList.
+type FunctionLibrary struct {
+	Name      string
+	Engine    string
+	Functions []FunctionInfo
+	Code      string // only set if requested
+}
+
+// FunctionInfo is a function of a library.
+type FunctionInfo struct {
+	Name        string
+	Description string
+	Flags       []string
+}
+
+// http://redis.io/commands/fcall
+// FCall calls a function on the server of its keys, which must all be on
+// the same server. Functions with no keys run on the first server. It
+// requires redis 7.0 or newer.
+func (c *Client) FCall(function string, keys, args []string) (interface{}, error) {
+	return c.eval("FCALL", function, len(keys), keys, args)
+}
+
+// http://redis.io/commands/fcall_ro
+// FCallRO is the read-only version of FCall, which can be served by
+// replicas. The function must have the no-writes flag.
+func (c *Client) FCallRO(function string, keys, args []string) (interface{}, error) {
+	return c.eval("FCALL_RO", function, len(keys), keys, args)
+}
+
+// http://redis.io/commands/function-delete
+// FunctionDelete deletes a library from every server.
+func (c *Client) FunctionDelete(library string) error {
+	_, err := c.execOnInstances(true, "FUNCTION", "DELETE", library)
+	return err
+}
+
+// http://redis.io/commands/function-dump
+// FunctionDump returns the serialized libraries of the first server, which
+// can be restored with FunctionRestore.
+func (c *Client) FunctionDump() (string, error) {
+	v, err := c.execOnFirst(true, "FUNCTION", "DUMP")
+	if err != nil {
+		return "", err
+	}
+	return iface2str(v)
+}
+
+// http://redis.io/commands/function-flush
+// FunctionFlush deletes all libraries from every server.
+func (c *Client) FunctionFlush() error {
+	_, err := c.execOnInstances(true, "FUNCTION", "FLUSH")
+	return err
+}
+
+// http://redis.io/commands/function-list
+// FunctionList returns the libraries of the first server whose name
+// matches pattern, or all of them if pattern is empty. Libraries loaded
+// with FunctionLoad are the same on every server.
+func (c *Client) FunctionList(pattern string, withCode bool) ([]FunctionLibrary, error) {
+	a := []interface{}{"FUNCTION", "LIST"}
+	if pattern != "" {
+		a = append(a, "LIBRARYNAME", pattern)
+	}
+	if withCode {
+		a = append(a, "WITHCODE")
+	}
+	v, err := c.execOnFirst(true, a...)
+	if err != nil {
+		return nil, err
+	}
+	items, _ := v.([]interface{})
+	r := make([]FunctionLibrary, len(items))
+	for n, item := range items {
+		if r[n], err = iface2library(item); err != nil {
+			return nil, err
+		}
+	}
+	return r, nil
+}
+
+// iface2library converts a library returned by FUNCTION LIST, which is a
+// list of names followed by their values.
+func iface2library(a interface{}) (FunctionLibrary, error) {
+	var l FunctionLibrary
+	items, ok := a.([]interface{})
+	if !ok || len(items)%2 != 0 {
+		return l, ErrServerError
+	}
+	for n := 0; n < len(items); n += 2 {
+		name, _ := items[n].(string)
+		switch name {
+		case "library_name":
+			l.Name, _ = iface2str(items[n+1])
+		case "engine":
+			l.Engine, _ = iface2str(items[n+1])
+		case "library_code":
+			l.Code, _ = iface2str(items[n+1])
+		case "functions":
+			fns, _ := items[n+1].([]interface{})
+			for _, fn := range fns {
+				f, ok := fn.([]interface{})
+				if !ok || len(f)%2 != 0 {
+					return l, ErrServerError
+				}
+				var info FunctionInfo
+				for i := 0; i < len(f); i += 2 {
+					switch f[i] {
+					case "name":
+						info.Name, _ = iface2str(f[i+1])
+					case "description":
+						info.Description, _ = iface2str(f[i+1])
+					case "flags":
+						info.Flags = iface2vstr(f[i+1])
+					}
+				}
+				l.Functions = append(l.Functions, info)
+			}
+		}
+	}
+	return l, nil
+}
+
+// http://redis.io/commands/function-load
+// FunctionLoad loads a library on every server, since FCall may call its
+// functions on any of them, and returns the name of the library. If
+// replace is true, an existing library with the same name is replaced,
+// otherwise it's an error. It requires redis 7.0 or newer.
+func (c *Client) FunctionLoad(code string, replace bool) (string, error) {
+	a := []interface{}{"FUNCTION", "LOAD"}
+	if replace {
+		a = append(a, "REPLACE")
+	}
+	r, err := c.execOnInstances(true, append(a, code)...)
+	if err != nil {
+		return "", err
+	}
+	for _, v := range r {
+		return iface2str(v)
+	}
+	return "", ErrServerError
+}
+
+// http://redis.io/commands/function-restore
+// FunctionRestore restores libraries serialized by FunctionDump on every
+// server. Policy is how existing libraries are handled: "APPEND" (default),
+// "REPLACE" or "FLUSH".
+func (c *Client) FunctionRestore(payload, policy string) error {
+	a := []interface{}{"FUNCTION", "RESTORE", payload}
+	if policy != "" {
+		a = append(a, policy)
+	}
+	_, err := c.execOnInstances(true, a...)
+	return err
+}
+
+// Library is a library of functions, registered on every server of a
+// client when first used.
+//
+// Example:
+//
+//	lib, err := redis.NewLibrary(`#!lua name=counters
+//		redis.register_function('incrmax', function(keys, args)
+//			...
+//		end)`)
+//	...
+//	v, err := lib.Call(rc, "incrmax", []string{"counter"}, "100")
+type Library struct {
+	Name string
+	Code string
+}
+
+// NewLibrary returns a Library with the given code, whose first line must
+// name it, e.g. "#!lua name=mylib".
+func NewLibrary(code string) (*Library, error) {
+	line := code
+	if n := strings.IndexByte(code, '\n'); n >= 0 {
+		line = code[:n]
+	}
+	if !strings.HasPrefix(line, "#!") {
+		return nil, errLibraryName
+	}
+	for _, f := range strings.Fields(line) {
+		if strings.HasPrefix(f, "name=") && len(f) > 5 {
+			return &Library{Name: f[5:], Code: code}, nil
+		}
+	}
+	return nil, errLibraryName
+}
+
+// Register loads the library on every server of c, replacing older
+// versions of it.
+func (l *Library) Register(c *Client) error {
+	_, err := c.FunctionLoad(l.Code, true)
+	return err
+}
+
+// Call calls a function of the library with FCall, registering the library
+// first if the server does not have the function.
+func (l *Library) Call(c *Client, function string, keys []string, args ...string) (interface{}, error) {
+	return l.call(c, "FCALL", function, keys, args)
+}
+
+// CallRO is the read-only version of Call.
+func (l *Library) CallRO(c *Client, function string, keys []string, args ...string) (interface{}, error) {
+	return l.call(c, "FCALL_RO", function, keys, args)
+}
+
+func (l *Library) call(c *Client, cmd, function string, keys, args []string) (interface{}, error) {
+	v, err := c.eval(cmd, function, len(keys), keys, args)
+	if e, ok := err.(Error); ok && strings.HasPrefix(string(e), "ERR Function not found") {
+		if err = l.Register(c); err != nil {
+			return nil, err
+		}
+		return c.eval(cmd, function, len(keys), keys, args)
+	}
+	return v, err
+}
@@ -0,0 +1,119 @@
+// Copyright 2013-2014 go-redis authors.  All rights reserved.
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+package redis
+
+import (
+	"reflect"
+	"testing"
+)
+
+const testLibrary = `#!lua name=gotest
+redis.register_function('gotest_get', function(keys, args) return {redis.call('GET', keys[1]), args[1]} end)`
+
+func TestNewLibrary(t *testing.T) {
+	l, err := NewLibrary(testLibrary)
+	if err != nil {
+		t.Fatal(err)
+	} else if l.Name != "gotest" {
+		t.Fatalf(errUnexpected, l.Name)
+	}
+	for _, code := range []string{"", "return 1", "#!lua\nname=gotest"} {
+		if _, err := NewLibrary(code); err != errLibraryName {
+			t.Fatalf(errUnexpected, err)
+		}
+	}
+}
+
+func TestFunction(t *testing.T) {
+	k := randomString(16)
+	defer rc.Del(k)
+	rc.Set(k, "hello", SetArgs{})
+	rc.FunctionDelete("gotest")
+	defer rc.FunctionDelete("gotest")
+	if name, err := rc.FunctionLoad(testLibrary, false); err != nil {
+		t.Fatal(err)
+	} else if name != "gotest" {
+		t.Fatalf(errUnexpected, name)
+	}
+	if _, err := rc.FunctionLoad(testLibrary, false); err == nil {
+		t.Fatal("FunctionLoad replaced a library")
+	}
+	if _, err := rc.FunctionLoad(testLibrary, true); err != nil {
+		t.Fatal(err)
+	}
+	libs, err := rc.FunctionList("gotest*", true)
+	if err != nil {
+		t.Fatal(err)
+	} else if len(libs) != 1 || libs[0].Name != "gotest" || libs[0].Engine != "LUA" ||
+		libs[0].Code != testLibrary || len(libs[0].Functions) != 1 ||
+		libs[0].Functions[0].Name != "gotest_get" {
+		t.Fatalf(errUnexpected, libs)
+	}
+	want := []interface{}{"hello", "world"}
+	if v, err := rc.FCall("gotest_get", []string{k}, []string{"world"}); err != nil {
+		t.Fatal(err)
+	} else if !reflect.DeepEqual(v, want) {
+		t.Fatalf(errUnexpected, v)
+	}
+	dump, err := rc.FunctionDump()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := rc.FunctionDelete("gotest"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := rc.FCall("gotest_get", []string{k}, nil); err == nil {
+		t.Fatal("FCall called a deleted function")
+	}
+	if err := rc.FunctionRestore(dump, "REPLACE"); err != nil {
+		t.Fatal(err)
+	}
+	if v, err := rc.FCall("gotest_get", []string{k}, []string{"world"}); err != nil {
+		t.Fatal(err)
+	} else if !reflect.DeepEqual(v, want) {
+		t.Fatalf(errUnexpected, v)
+	}
+}
+
+func TestLibrarySharded(t *testing.T) {
+	c := New("127.0.0.1:6379", "127.0.0.1:6379 db=1")
+	keys := keysOnDistinctServers(t, c, "function", 2)
+	defer c.Del(keys[0], keys[1])
+	c.Set(keys[0], "db0", SetArgs{})
+	c.Set(keys[1], "db1", SetArgs{})
+	c.FunctionDelete("gotest")
+	defer c.FunctionDelete("gotest")
+	// Libraries are loaded once per redis instance, not per database.
+	if _, err := c.FunctionLoad(testLibrary, false); err != nil {
+		t.Fatal(err)
+	}
+	c.FunctionDelete("gotest")
+	l, err := NewLibrary(testLibrary)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Call registers the library, which is not loaded yet.
+	want := []string{"db0", "db1"}
+	for i, k := range keys {
+		if v, err := l.Call(c, "gotest_get", []string{k}, "x"); err != nil {
+			t.Fatal(err)
+		} else if !reflect.DeepEqual(v, []interface{}{want[i], "x"}) {
+			t.Fatalf(errUnexpected, v)
+		}
+	}
+	if _, err := l.Call(c, "gotest_get", []string{keys[0], keys[1]}); err != ErrCrossShard {
+		t.Fatalf(errUnexpected, err)
+	}
+}
@@ -0,0 +1,265 @@
+// Copyright 2013-2014 go-redis authors.  All rights reserved.
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+package redis
+
+import (
+	"strconv"
+)
+
+// GeoLocation is a member of a geospatial index. The results of GeoSearch
+// only have the fields requested by its WITH options, besides Name.
+type GeoLocation struct {
+	Name string
+	Lon  float64
+	Lat  float64
+	Dist float64 // distance from the center of the search, in its unit
+	Hash int64   // raw geohash, the score of the member in the sorted set
+}
+
+// GeoSearchQuery are the arguments of GEOSEARCH and GEOSEARCHSTORE.
+type GeoSearchQuery struct {
+	// Member is the member used as the center of the search. If empty,
+	// Lon and Lat are used.
+	Member string
+	Lon    float64
+	Lat    float64
+
+	// Radius searches within a circle. If zero, the box of Width and
+	// Height is used.
+	Radius float64
+	Width  float64
+	Height float64
+
+	// Unit is the unit of Radius, Width, Height and the distances
+	// returned: "m", "km", "ft" or "mi". If empty, "m" is used.
+	Unit string
+
+	// Sort is "ASC" or "DESC" to sort by distance from the center.
+	// If empty, the results are not sorted.
+	Sort string
+
+	// Count limits the number of results. If Any is set, the search
+	// stops as soon as Count results are found, which are not the
+	// closest ones.
+	Count int
+	Any   bool
+
+	// WithCoord, WithDist and WithHash set the respective fields of
+	// the results of GeoSearch. They're not used by GeoSearchStore.
+	WithCoord bool
+	WithDist  bool
+	WithHash  bool
+}
+
+// args returns the query in the form of command arguments, except for the
+// WITH options.
+func (q GeoSearchQuery) args() []interface{} {
+	var a []interface{}
+	if q.Member != "" {
+		a = append(a, "FROMMEMBER", q.Member)
+	} else {
+		a = append(a, "FROMLONLAT", q.Lon, q.Lat)
+	}
+	unit := q.Unit
+	if unit == "" {
+		unit = "m"
+	}
+	if q.Radius != 0 {
+		a = append(a, "BYRADIUS", q.Radius, unit)
+	} else {
+		a = append(a, "BYBOX", q.Width, q.Height, unit)
+	}
+	if q.Sort != "" {
+		a = append(a, q.Sort)
+	}
+	if q.Count > 0 {
+		a = append(a, "COUNT", q.Count)
+		if q.Any {
+			a = append(a, "ANY")
+		}
+	}
+	return a
+}
+
+// iface2coord converts a position, [lon, lat].
+func iface2coord(a interface{}) (lon, lat float64, err error) {
+	r := iface2vstr(a)
+	if len(r) != 2 {
+		return 0, 0, ErrServerError
+	}
+	if lon, err = strconv.ParseFloat(r[0], 64); err != nil {
+		return
+	}
+	lat, err = strconv.ParseFloat(r[1], 64)
+	return
+}
+
+// http://redis.io/commands/geoadd
+// GeoAdd adds members to a geospatial index, or updates their position,
+// and returns the number of members added.
+func (c *Client) GeoAdd(key string, locations ...GeoLocation) (int, error) {
+	a := make([]interface{}, 0, len(locations)*3)
+	for _, l := range locations {
+		a = append(a, l.Lon, l.Lat, l.Name)
+	}
+	v, err := c.execWithKey(true, "GEOADD", key, a...)
+	if err != nil {
+		return 0, err
+	}
+	return iface2int(v)
+}
+
+// http://redis.io/commands/geodist
+// GeoDist returns the distance between two members in the given unit, or
+// in meters if unit is empty. It returns ErrNil if any of the members does
+// not exist.
+func (c *Client) GeoDist(key, member1, member2, unit string) (float64, error) {
+	a := []interface{}{member1, member2}
+	if unit != "" {
+		a = append(a, unit)
+	}
+	v, err := c.execWithKey(true, "GEODIST", key, a...)
+	if err != nil {
+		return 0, err
+	}
+	return iface2score(v)
+}
+
+// http://redis.io/commands/geohash
+// GeoHash returns the geohash strings of members, in the same order they're
+// given. Members that do not exist have an empty hash.
+func (c *Client) GeoHash(key string, members ...string) ([]string, error) {
+	v, err := c.execWithKey(true, "GEOHASH", key, vstr2iface(members)...)
+	if err != nil {
+		return nil, err
+	}
+	return iface2vstr(v), nil
+}
+
+// http://redis.io/commands/geopos
+// GeoPos returns the positions of members, in the same order they're
+// given. Members that do not exist are nil.
+func (c *Client) GeoPos(key string, members ...string) ([]*GeoLocation, error) {
+	v, err := c.execWithKey(true, "GEOPOS", key, vstr2iface(members)...)
+	if err != nil {
+		return nil, err
+	}
+	items, ok := v.([]interface{})
+	if !ok || len(items) != len(members) {
+		return nil, ErrServerError
+	}
+	r := make([]*GeoLocation, len(items))
+	for n, item := range items {
+		if item == nil {
+			continue
+		}
+		lon, lat, err := iface2coord(item)
+		if err != nil {
+			return nil, err
+		}
+		r[n] = &GeoLocation{Name: members[n], Lon: lon, Lat: lat}
+	}
+	return r, nil
+}
+
+// http://redis.io/commands/geosearch
+// GeoSearch returns the members within an area of a geospatial index.
+// It requires redis 6.2 or newer.
+func (c *Client) GeoSearch(key string, q GeoSearchQuery) ([]GeoLocation, error) {
+	a := q.args()
+	if q.WithCoord {
+		a = append(a, "WITHCOORD")
+	}
+	if q.WithDist {
+		a = append(a, "WITHDIST")
+	}
+	if q.WithHash {
+		a = append(a, "WITHHASH")
+	}
+	v, err := c.execWithKey(true, "GEOSEARCH", key, a...)
+	if err != nil {
+		return nil, err
+	}
+	items, _ := v.([]interface{})
+	r := make([]GeoLocation, len(items))
+	for n, item := range items {
+		if r[n], err = iface2geo(item, q); err != nil {
+			return nil, err
+		}
+	}
+	return r, nil
+}
+
+// iface2geo converts a result of GEOSEARCH, which is the name of the member
+// or, if any WITH option is set, [name, dist, hash, [lon, lat]] with only
+// the fields requested.
+func iface2geo(a interface{}, q GeoSearchQuery) (GeoLocation, error) {
+	var l GeoLocation
+	if !q.WithCoord && !q.WithDist && !q.WithHash {
+		name, err := iface2str(a)
+		l.Name = name
+		return l, err
+	}
+	r, ok := a.([]interface{})
+	if !ok || len(r) < 2 {
+		return l, ErrServerError
+	}
+	var err error
+	if l.Name, err = iface2str(r[0]); err != nil {
+		return l, err
+	}
+	r = r[1:]
+	if q.WithDist {
+		if l.Dist, err = iface2score(r[0]); err != nil {
+			return l, err
+		}
+		r = r[1:]
+	}
+	if q.WithHash && len(r) > 0 {
+		hash, err := iface2int(r[0])
+		if err != nil {
+			return l, err
+		}
+		l.Hash = int64(hash)
+		r = r[1:]
+	}
+	if q.WithCoord && len(r) > 0 {
+		if l.Lon, l.Lat, err = iface2coord(r[0]); err != nil {
+			return l, err
+		}
+	}
+	return l, nil
+}
+
+// http://redis.io/commands/geosearchstore
+// GeoSearchStore is like GeoSearch, but stores the members found in dst
+// and returns their number. If storeDist is true, members are stored with
+// their distance as score instead of their position. Both keys must be on
+// the same server. It requires redis 6.2 or newer.
+func (c *Client) GeoSearchStore(dst, src string, q GeoSearchQuery, storeDist bool) (int, error) {
+	srv, err := c.pickServerForKeys("GEOSEARCHSTORE", []string{dst, src})
+	if err != nil {
+		return 0, err
+	}
+	a := append([]interface{}{"GEOSEARCHSTORE", dst, src}, q.args()...)
+	if storeDist {
+		a = append(a, "STOREDIST")
+	}
+	v, err := c.execWithAddr(true, srv, a...)
+	if err != nil {
+		return 0, err
+	}
+	return iface2int(v)
+}
@@ -0,0 +1,110 @@
+// Copyright 2013-2014 go-redis authors.  All rights reserved.
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+package redis
+
+import (
+	"math"
+	"reflect"
+	"testing"
+)
+
+var sicily = []GeoLocation{
+	{Name: "Palermo", Lon: 13.361389, Lat: 38.115556},
+	{Name: "Catania", Lon: 15.087269, Lat: 37.502669},
+}
+
+func TestGeoSearchQueryArgs(t *testing.T) {
+	tests := []struct {
+		q    GeoSearchQuery
+		args []interface{}
+	}{
+		{GeoSearchQuery{Lon: 15, Lat: 37, Radius: 200, Unit: "km"},
+			[]interface{}{"FROMLONLAT", 15.0, 37.0, "BYRADIUS", 200.0, "km"}},
+		{GeoSearchQuery{Member: "Palermo", Width: 400, Height: 400, Sort: "ASC", Count: 1, Any: true},
+			[]interface{}{"FROMMEMBER", "Palermo", "BYBOX", 400.0, 400.0, "m", "ASC", "COUNT", 1, "ANY"}},
+	}
+	for _, test := range tests {
+		if a := test.q.args(); !reflect.DeepEqual(a, test.args) {
+			t.Fatalf(errUnexpected, a)
+		}
+	}
+}
+
+// TestGeo reproduces the examples from http://redis.io/commands/geosearch.
+func TestGeo(t *testing.T) {
+	rc.Del("Sicily")
+	defer rc.Del("Sicily")
+	if n, err := rc.GeoAdd("Sicily", sicily...); err != nil {
+		t.Fatal(err)
+	} else if n != 2 {
+		t.Fatalf(errUnexpected, n)
+	}
+	if d, err := rc.GeoDist("Sicily", "Palermo", "Catania", "km"); err != nil {
+		t.Fatal(err)
+	} else if math.Abs(d-166.27) > 0.01 {
+		t.Fatalf(errUnexpected, d)
+	}
+	if _, err := rc.GeoDist("Sicily", "Palermo", "Agrigento", ""); err != ErrNil {
+		t.Fatalf(errUnexpected, err)
+	}
+	if v, err := rc.GeoHash("Sicily", "Palermo", "Agrigento"); err != nil {
+		t.Fatal(err)
+	} else if !reflect.DeepEqual(v, []string{"sqc8b49rny0", ""}) {
+		t.Fatalf(errUnexpected, v)
+	}
+	pos, err := rc.GeoPos("Sicily", "Palermo", "Agrigento")
+	if err != nil {
+		t.Fatal(err)
+	} else if len(pos) != 2 || pos[0] == nil || pos[1] != nil ||
+		math.Abs(pos[0].Lon-13.361389) > 1e-5 || math.Abs(pos[0].Lat-38.115556) > 1e-5 {
+		t.Fatalf(errUnexpected, pos)
+	}
+	r, err := rc.GeoSearch("Sicily", GeoSearchQuery{
+		Lon: 15, Lat: 37, Radius: 200, Unit: "km", Sort: "ASC",
+		WithCoord: true, WithDist: true, WithHash: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	} else if len(r) != 2 || r[0].Name != "Catania" || math.Abs(r[0].Dist-56.4413) > 0.001 ||
+		r[0].Hash != 3479447370796909 || math.Abs(r[0].Lon-15.087269) > 1e-5 {
+		t.Fatalf(errUnexpected, r)
+	}
+	r, err = rc.GeoSearch("Sicily", GeoSearchQuery{
+		Member: "Palermo", Width: 400, Height: 400, Unit: "km", Sort: "DESC",
+	})
+	if err != nil {
+		t.Fatal(err)
+	} else if len(r) != 2 || r[0].Name != "Catania" || r[0].Dist != 0 {
+		t.Fatalf(errUnexpected, r)
+	}
+}
+
+func TestGeoSearchStore(t *testing.T) {
+	src, dst := "{Sicily}", "{Sicily}dst"
+	rc.Del(src, dst)
+	defer rc.Del(src, dst)
+	rc.GeoAdd(src, sicily...)
+	q := GeoSearchQuery{Lon: 15, Lat: 37, Radius: 100, Unit: "km"}
+	if n, err := rc.GeoSearchStore(dst, src, q, true); err != nil {
+		t.Fatal(err)
+	} else if n != 1 {
+		t.Fatalf(errUnexpected, n)
+	}
+	if v, err := rc.ZRangeWithScores(dst, 0, -1); err != nil {
+		t.Fatal(err)
+	} else if len(v) != 1 || v[0].Member != "Catania" || math.Abs(v[0].Score-56.4413) > 0.001 {
+		t.Fatalf(errUnexpected, v)
+	}
+}
@@ -0,0 +1,285 @@
+// Copyright 2013-2014 go-redis authors.  All rights reserved.
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+package redis
+
+import (
+	"encoding"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+var textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+
+// structField is a field of a struct mapped to a field of a hash.
+type structField struct {
+	index     []int
+	name      string
+	omitEmpty bool
+}
+
+// structFields returns the fields of a struct type that are mapped to
+// fields of a hash, named by their `redis:"name,omitempty"` tag or by
+// their Go name. Unexported fields and fields tagged "-" are skipped.
+// The fields of untagged embedded structs are promoted, unless there is
+// a field with the same name at a shallower depth.
+func structFields(t reflect.Type) []structField {
+	var r []structField
+	seen := make(map[string]bool)
+	for _, f := range allFields(t, nil) {
+		if !seen[f.name] {
+			seen[f.name] = true
+			r = append(r, f)
+		}
+	}
+	return r
+}
+
+// allFields returns the mapped fields of t and its embedded structs,
+// ordered by depth.
+func allFields(t reflect.Type, index []int) []structField {
+	var r, embedded []structField
+	for n := 0; n < t.NumField(); n++ {
+		f := t.Field(n)
+		tag := f.Tag.Get("redis")
+		if tag == "-" {
+			continue
+		}
+		opts := strings.Split(tag, ",")
+		fi := append(append([]int(nil), index...), n)
+		if f.Anonymous && opts[0] == "" {
+			ft := f.Type
+			if ft.Kind() == reflect.Ptr {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct && !ft.Implements(textMarshalerType) &&
+				!reflect.PtrTo(ft).Implements(textMarshalerType) {
+				// Pointers to unexported structs cannot be allocated.
+				if f.PkgPath == "" || f.Type.Kind() != reflect.Ptr {
+					embedded = append(embedded, allFields(ft, fi)...)
+				}
+				continue
+			}
+		}
+		if f.PkgPath != "" {
+			continue
+		}
+		sf := structField{index: fi, name: f.Name}
+		if opts[0] != "" {
+			sf.name = opts[0]
+		}
+		for _, opt := range opts[1:] {
+			if opt == "omitempty" {
+				sf.omitEmpty = true
+			}
+		}
+		r = append(r, sf)
+	}
+	sort.SliceStable(embedded, func(i, j int) bool {
+		return len(embedded[i].index) < len(embedded[j].index)
+	})
+	return append(r, embedded...)
+}
+
+// fieldByIndex returns the field of v at index. Nil pointers to embedded
+// structs are allocated if alloc is true, or make it return false.
+func fieldByIndex(v reflect.Value, index []int, alloc bool) (reflect.Value, bool) {
+	for n, i := range index {
+		if n > 0 && v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				if !alloc {
+					return v, false
+				}
+				v.Set(reflect.New(v.Type().Elem()))
+			}
+			v = v.Elem()
+		}
+		v = v.Field(i)
+	}
+	return v, true
+}
+
+// HSetStruct sets the fields of a hash from the exported fields of the
+// struct v, or pointer to it. Fields are named by their `redis:"name"`
+// tag, or by their Go name, and skipped if tagged "-".
+//
+// Supported types are strings, []byte, ints, uints, floats, bool,
+// time.Time and types that implement encoding.TextMarshaler, and pointers
+// to them. Nil pointers are not set, nor are zero values of fields tagged
+// with omitempty, e.g. `redis:"email,omitempty"`. Fields that are not set
+// keep their previous value in the hash, if any. The fields of embedded
+// structs are set as if they were fields of v, unless the embedded struct
+// is tagged with a name. Embedded pointers to unexported structs are
+// skipped, since HGetAllStruct cannot allocate them.
+//
+// Example:
+//
+//	type User struct {
+//		Name    string    `redis:"name"`
+//		Age     int       `redis:"age,omitempty"`
+//		Created time.Time `redis:"created"`
+//		Email   *string   `redis:"email"`
+//	}
+//
+//	err := rc.HSetStruct("user:1", &User{Name: "Ana", Created: time.Now()})
+func (c *Client) HSetStruct(key string, v interface{}) error {
+	rv := reflect.Indirect(reflect.ValueOf(v))
+	if rv.Kind() != reflect.Struct {
+		return ErrInvalidType
+	}
+	if !rv.CanAddr() {
+		// Make the fields addressable, for pointer receiver MarshalText.
+		p := reflect.New(rv.Type())
+		p.Elem().Set(rv)
+		rv = p.Elem()
+	}
+	var a []interface{}
+	for _, f := range structFields(rv.Type()) {
+		fv, ok := fieldByIndex(rv, f.index, false)
+		if !ok {
+			continue
+		}
+		if f.omitEmpty && fv.IsZero() {
+			continue
+		}
+		if fv.Kind() == reflect.Ptr {
+			if fv.IsNil() {
+				continue
+			}
+			fv = fv.Elem()
+		}
+		s, err := encodeField(fv)
+		if err != nil {
+			return fmt.Errorf("field %s: %v", f.name, err)
+		}
+		a = append(a, f.name, s)
+	}
+	if len(a) == 0 {
+		return nil
+	}
+	_, err := c.execWithKey(true, "HSET", key, a...)
+	return err
+}
+
+// HGetAllStruct sets the fields of the struct pointed at by v from the
+// fields of a hash, as mapped by HSetStruct. Fields of the struct that are
+// not in the hash are left unchanged. It returns ErrNil if the hash does
+// not exist.
+func (c *Client) HGetAllStruct(key string, v interface{}) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() || rv.Elem().Kind() != reflect.Struct {
+		return ErrInvalidType
+	}
+	m, err := c.HGetAll(key)
+	if err != nil {
+		return err
+	}
+	if len(m) == 0 {
+		return ErrNil
+	}
+	rv = rv.Elem()
+	for _, f := range structFields(rv.Type()) {
+		s, ok := m[f.name]
+		if !ok {
+			continue
+		}
+		fv, _ := fieldByIndex(rv, f.index, true)
+		if fv.Kind() == reflect.Ptr {
+			p := reflect.New(fv.Type().Elem())
+			if err := decodeField(p.Elem(), s); err != nil {
+				return fmt.Errorf("field %s: %v", f.name, err)
+			}
+			fv.Set(p)
+			continue
+		}
+		if err := decodeField(fv, s); err != nil {
+			return fmt.Errorf("field %s: %v", f.name, err)
+		}
+	}
+	return nil
+}
+
+// encodeField converts the value of a struct field to a field of a hash.
+func encodeField(v reflect.Value) (string, error) {
+	if !v.Type().Implements(textMarshalerType) && v.CanAddr() &&
+		v.Addr().Type().Implements(textMarshalerType) {
+		v = v.Addr()
+	}
+	if v.Type().Implements(textMarshalerType) {
+		b, err := v.Interface().(encoding.TextMarshaler).MarshalText()
+		return string(b), err
+	}
+	switch v.Kind() {
+	case reflect.String:
+		return v.String(), nil
+	case reflect.Bool:
+		return strconv.FormatBool(v.Bool()), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(v.Int(), 10), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.FormatUint(v.Uint(), 10), nil
+	case reflect.Float32, reflect.Float64:
+		return strconv.FormatFloat(v.Float(), 'g', -1, v.Type().Bits()), nil
+	case reflect.Slice:
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			return string(v.Bytes()), nil
+		}
+	}
+	return "", fmt.Errorf("unsupported type %s", v.Type())
+}
+
+// decodeField sets a struct field from a field of a hash.
+func decodeField(v reflect.Value, s string) error {
+	if u, ok := v.Addr().Interface().(encoding.TextUnmarshaler); ok {
+		return u.UnmarshalText([]byte(s))
+	}
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(s)
+		return nil
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err == nil {
+			v.SetBool(b)
+		}
+		return err
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(s, 10, v.Type().Bits())
+		if err == nil {
+			v.SetInt(n)
+		}
+		return err
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(s, 10, v.Type().Bits())
+		if err == nil {
+			v.SetUint(n)
+		}
+		return err
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(s, v.Type().Bits())
+		if err == nil {
+			v.SetFloat(f)
+		}
+		return err
+	case reflect.Slice:
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			v.SetBytes([]byte(s))
+			return nil
+		}
+	}
+	return fmt.Errorf("unsupported type %s", v.Type())
+}
@@ -0,0 +1,171 @@
+// Copyright 2013-2014 go-redis authors.  All rights reserved.
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+package redis
+
+import (
+	"fmt"
+	"net"
+	"reflect"
+	"testing"
+	"time"
+)
+
+type testUser struct {
+	Name    string    `redis:"name"`
+	Age     int       `redis:"age,omitempty"`
+	Score   float64   `redis:"score"`
+	Admin   bool      `redis:"admin"`
+	Created time.Time `redis:"created"`
+	Avatar  []byte    `redis:"avatar"`
+	Email   *string   `redis:"email"`
+	IP      net.IP    `redis:"ip"`
+	Visits  uint32
+	Secret  string `redis:"-"`
+	private string
+}
+
+// testPoint implements encoding.TextMarshaler with a pointer receiver.
+type testPoint struct{ X, Y int }
+
+func (p *testPoint) MarshalText() ([]byte, error) {
+	return []byte(fmt.Sprintf("%d,%d", p.X, p.Y)), nil
+}
+
+func (p *testPoint) UnmarshalText(b []byte) error {
+	_, err := fmt.Sscanf(string(b), "%d,%d", &p.X, &p.Y)
+	return err
+}
+
+type testBase struct {
+	ID   int    `redis:"id"`
+	Name string `redis:"name"`
+}
+
+type ExtraFields struct {
+	Note string `redis:"note"`
+}
+
+type testEmbedded struct {
+	testBase
+	*ExtraFields
+	Name string    `redis:"name"`
+	Pos  testPoint `redis:"pos"`
+}
+
+func TestHashStruct(t *testing.T) {
+	k := randomString(16)
+	defer rc.Del(k)
+	email := "ana@example.com"
+	u := testUser{
+		Name:    "Ana",
+		Score:   1.5,
+		Admin:   true,
+		Created: time.Date(2014, 1, 2, 3, 4, 5, 6, time.UTC),
+		Avatar:  []byte{0, 1, 2},
+		Email:   &email,
+		IP:      net.ParseIP("10.0.0.1"),
+		Visits:  7,
+		Secret:  "secret",
+		private: "private",
+	}
+	if err := rc.HSetStruct(k, &u); err != nil {
+		t.Fatal(err)
+	}
+	m, err := rc.HGetAll(k)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{
+		"name":    "Ana",
+		"score":   "1.5",
+		"admin":   "true",
+		"created": "2014-01-02T03:04:05.000000006Z",
+		"avatar":  "\x00\x01\x02",
+		"email":   "ana@example.com",
+		"ip":      "10.0.0.1",
+		"Visits":  "7",
+	}
+	if !reflect.DeepEqual(m, want) {
+		t.Fatalf(errUnexpected, m)
+	}
+	var r testUser
+	if err := rc.HGetAllStruct(k, &r); err != nil {
+		t.Fatal(err)
+	}
+	u.Secret, u.private = "", ""
+	if !reflect.DeepEqual(r, u) {
+		t.Fatalf(errUnexpected, r)
+	}
+	// Nil pointers are not set, and fields not in the hash are unchanged.
+	rc.Del(k)
+	rc.HSetStruct(k, testUser{Name: "Bob"})
+	r = testUser{Age: 30}
+	if err := rc.HGetAllStruct(k, &r); err != nil {
+		t.Fatal(err)
+	} else if r.Name != "Bob" || r.Age != 30 || r.Email != nil {
+		t.Fatalf(errUnexpected, r)
+	}
+	rc.HSet(k, "age", "old")
+	if err := rc.HGetAllStruct(k, &r); err == nil {
+		t.Fatal("HGetAllStruct decoded an invalid int")
+	} else if r.Age != 30 {
+		t.Fatalf(errUnexpected, r.Age)
+	}
+}
+
+func TestHashStructEmbedded(t *testing.T) {
+	k := randomString(16)
+	defer rc.Del(k)
+	// Passed by value, with a nil embedded pointer.
+	v := testEmbedded{testBase{7, "base"}, nil, "outer", testPoint{1, 2}}
+	if err := rc.HSetStruct(k, v); err != nil {
+		t.Fatal(err)
+	}
+	m, err := rc.HGetAll(k)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{"id": "7", "name": "outer", "pos": "1,2"}
+	if !reflect.DeepEqual(m, want) {
+		t.Fatalf(errUnexpected, m)
+	}
+	rc.HSet(k, "note", "hello")
+	var r testEmbedded
+	if err := rc.HGetAllStruct(k, &r); err != nil {
+		t.Fatal(err)
+	}
+	v.testBase.Name = ""
+	v.ExtraFields = &ExtraFields{"hello"}
+	if !reflect.DeepEqual(r, v) {
+		t.Fatalf(errUnexpected, r)
+	}
+}
+
+func TestHashStructErrors(t *testing.T) {
+	k := randomString(16)
+	if err := rc.HGetAllStruct(k, &testUser{}); err != ErrNil {
+		t.Fatalf(errUnexpected, err)
+	}
+	if err := rc.HGetAllStruct(k, testUser{}); err != ErrInvalidType {
+		t.Fatalf(errUnexpected, err)
+	}
+	if err := rc.HSetStruct(k, "string"); err != ErrInvalidType {
+		t.Fatalf(errUnexpected, err)
+	}
+	if err := rc.HSetStruct(k, struct{ C chan int }{}); err == nil {
+		rc.Del(k)
+		t.Fatal("HSetStruct encoded a channel")
+	}
+}
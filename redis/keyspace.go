@@ -0,0 +1,194 @@
+// Copyright 2013-2014 go-redis authors.  All rights reserved.
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+package redis
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Prefixes of the channels of keyspace notifications.
+// See http://redis.io/topics/notifications for details.
+const (
+	keyspacePrefix = "__keyspace@"
+	keyeventPrefix = "__keyevent@"
+)
+
+// KeyspaceEvent is a keyspace notification.
+type KeyspaceEvent struct {
+	DB    int    // database of the key
+	Key   string // key that changed
+	Event string // name of the event, e.g. "set", "del" or "expired"
+}
+
+// KeyspaceNotifications receives the keyspace notifications of every
+// server. Each server is subscribed to the notifications of its own
+// database, as configured in ServerInfo.DB.
+//
+// Example:
+//
+//	kn := rc.NewKeyspaceNotifications()
+//	kn.Events = "Kx" // expirations only
+//	defer kn.Close()
+//	if err := kn.Watch("session:*"); err != nil {
+//		...
+//	}
+//	for {
+//		ev, err := kn.Receive()
+//		if err != nil {
+//			...
+//		}
+//		fmt.Println(ev.Key, ev.Event)
+//	}
+type KeyspaceNotifications struct {
+	// Events, if not empty, is set as notify-keyspace-events on every
+	// server with CONFIG SET before subscribing, e.g. "KEA" for all
+	// events. Redis does not send notifications unless configured.
+	Events string
+
+	// PubSub holds the subscriptions. Its options, such as Reconnect,
+	// must be set before calling Watch or WatchEvents.
+	PubSub *PubSub
+}
+
+// NewKeyspaceNotifications returns KeyspaceNotifications with no
+// subscriptions.
+func (c *Client) NewKeyspaceNotifications() *KeyspaceNotifications {
+	return &KeyspaceNotifications{PubSub: c.NewPubSub()}
+}
+
+// groups returns the channel patterns of each server, which are names
+// prefixed with the keyspace or keyevent channel of the server's database.
+func (kn *KeyspaceNotifications) groups(prefix string, names []string) ([]*keyGroup, error) {
+	servers, err := kn.PubSub.c.Servers()
+	if err != nil {
+		return nil, err
+	}
+	groups := make([]*keyGroup, len(servers))
+	for n, srv := range servers {
+		db := srv.DB
+		if db == "" {
+			db = "0"
+		}
+		g := &keyGroup{srv: srv, keys: make([]string, len(names))}
+		for i, name := range names {
+			g.keys[i] = prefix + db + "__:" + name
+		}
+		groups[n] = g
+	}
+	return groups, nil
+}
+
+func (kn *KeyspaceNotifications) watch(prefix string, names []string) error {
+	if kn.Events != "" {
+		if err := kn.PubSub.c.ConfigSet("notify-keyspace-events", kn.Events); err != nil {
+			return err
+		}
+	}
+	groups, err := kn.groups(prefix, names)
+	if err != nil {
+		return err
+	}
+	return kn.PubSub.subscribeGroups("PSUBSCRIBE", groups, patternsOf)
+}
+
+func (kn *KeyspaceNotifications) unwatch(prefix string, names []string) error {
+	if len(names) == 0 {
+		return kn.PubSub.unsubscribeGroups("PUNSUBSCRIBE", nil, patternsOf)
+	}
+	groups, err := kn.groups(prefix, names)
+	if err != nil {
+		return err
+	}
+	return kn.PubSub.unsubscribeGroups("PUNSUBSCRIBE", groups, patternsOf)
+}
+
+// Watch subscribes to the notifications of keys that match the given
+// glob-style patterns, e.g. "*" for all keys. It requires the K flag in
+// notify-keyspace-events.
+func (kn *KeyspaceNotifications) Watch(patterns ...string) error {
+	return kn.watch(keyspacePrefix, patterns)
+}
+
+// Unwatch unsubscribes from the given key patterns, or from all key and
+// event patterns if none is given.
+func (kn *KeyspaceNotifications) Unwatch(patterns ...string) error {
+	return kn.unwatch(keyspacePrefix, patterns)
+}
+
+// WatchEvents subscribes to the notifications of the given events of any
+// key, e.g. "expired" or "del". It requires the E flag in
+// notify-keyspace-events.
+func (kn *KeyspaceNotifications) WatchEvents(events ...string) error {
+	return kn.watch(keyeventPrefix, events)
+}
+
+// UnwatchEvents unsubscribes from the given events, or from all key and
+// event patterns if none is given.
+func (kn *KeyspaceNotifications) UnwatchEvents(events ...string) error {
+	return kn.unwatch(keyeventPrefix, events)
+}
+
+// Receive waits for the next notification. Other events of PubSub are
+// discarded, including Reconnect: notifications sent while disconnected
+// are lost, see PubSub.Stats.
+func (kn *KeyspaceNotifications) Receive() (KeyspaceEvent, error) {
+	for {
+		ev, err := kn.PubSub.Receive()
+		if err != nil {
+			return KeyspaceEvent{}, err
+		}
+		m, ok := ev.(PMessage)
+		if !ok {
+			continue
+		}
+		if ke, ok := parseKeyspaceEvent(m.Channel, m.Data); ok {
+			return ke, nil
+		}
+	}
+}
+
+// Close closes all connections.
+func (kn *KeyspaceNotifications) Close() error {
+	return kn.PubSub.Close()
+}
+
+// parseKeyspaceEvent decodes a message of a keyspace channel, whose data
+// is the event, or of a keyevent channel, whose data is the key.
+func parseKeyspaceEvent(channel, data string) (KeyspaceEvent, bool) {
+	var keyspace bool
+	switch {
+	case strings.HasPrefix(channel, keyspacePrefix):
+		keyspace = true
+	case strings.HasPrefix(channel, keyeventPrefix):
+	default:
+		return KeyspaceEvent{}, false
+	}
+	// Both prefixes have the same length.
+	s := channel[len(keyspacePrefix):]
+	n := strings.Index(s, "__:")
+	if n < 0 {
+		return KeyspaceEvent{}, false
+	}
+	db, err := strconv.Atoi(s[:n])
+	if err != nil {
+		return KeyspaceEvent{}, false
+	}
+	name := s[n+3:]
+	if keyspace {
+		return KeyspaceEvent{DB: db, Key: name, Event: data}, true
+	}
+	return KeyspaceEvent{DB: db, Key: data, Event: name}, true
+}
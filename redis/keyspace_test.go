@@ -0,0 +1,84 @@
+// Copyright 2013-2014 go-redis authors.  All rights reserved.
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+package redis
+
+import (
+	"strconv"
+	"testing"
+)
+
+func TestParseKeyspaceEvent(t *testing.T) {
+	tests := []struct {
+		channel, data string
+		ev            KeyspaceEvent
+		ok            bool
+	}{
+		{"__keyspace@0__:foo", "set", KeyspaceEvent{0, "foo", "set"}, true},
+		{"__keyspace@5__:a:b__:c", "del", KeyspaceEvent{5, "a:b__:c", "del"}, true},
+		{"__keyevent@1__:expired", "foo", KeyspaceEvent{1, "foo", "expired"}, true},
+		{"__keyspace@x__:foo", "set", KeyspaceEvent{}, false},
+		{"__keyspace@0", "set", KeyspaceEvent{}, false},
+		{"news", "hello", KeyspaceEvent{}, false},
+	}
+	for _, test := range tests {
+		ev, ok := parseKeyspaceEvent(test.channel, test.data)
+		if ok != test.ok || ev != test.ev {
+			t.Fatalf(errUnexpected, test.channel)
+		}
+	}
+}
+
+func TestKeyspaceNotifications(t *testing.T) {
+	c := New("127.0.0.1:6379", "127.0.0.1:6379 db=1")
+	kn := c.NewKeyspaceNotifications()
+	kn.Events = "KA"
+	defer kn.Close()
+	prefix := randomString(16)
+	if err := kn.Watch(prefix + ":*"); err != nil {
+		t.Fatal(err)
+	}
+	// Wait until both servers are subscribed.
+	for i := 0; i < 2; i++ {
+		if _, err := kn.PubSub.Receive(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	keys := make(map[string]int)
+	var all []string
+	for i := 0; i < 8; i++ {
+		k := prefix + ":" + strconv.Itoa(i)
+		srv, err := c.selector.PickServer(k)
+		if err != nil {
+			t.Fatal(err)
+		}
+		keys[k], _ = strconv.Atoi(srv.DB)
+		all = append(all, k)
+		if _, _, err = c.Set(k, "v", SetArgs{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for len(keys) > 0 {
+		ev, err := kn.Receive()
+		if err != nil {
+			t.Fatal(err)
+		}
+		db, ok := keys[ev.Key]
+		if !ok || ev.DB != db || ev.Event != "set" {
+			t.Fatalf(errUnexpected, ev)
+		}
+		delete(keys, ev.Key)
+	}
+	c.Del(all...)
+}
@@ -0,0 +1,760 @@
+// Copyright 2013-2014 go-redis authors.  All rights reserved.
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+package redis
+
+import (
+	"errors"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultPingInterval is the default interval between keepalive PINGs
+// sent by PubSub.
+const DefaultPingInterval = 30 * time.Second
+
+// ErrPubSubClosed is returned by PubSub after Close is called.
+var ErrPubSubClosed = errors.New("pubsub closed")
+
+// pubSubKeepAlive is the payload of keepalive PINGs. Their replies are
+// not delivered to the application.
+const pubSubKeepAlive = "go-redis:keepalive"
+
+// pubSubBufferSize is the number of events buffered by PubSub.
+const pubSubBufferSize = 100
+
+// Default bounds of the backoff between reconnection attempts of PubSub.
+const (
+	DefaultMinBackoff = 100 * time.Millisecond
+	DefaultMaxBackoff = 30 * time.Second
+)
+
+// OverflowPolicy determines what PubSub does with messages received while
+// its buffer of events is full, because the application is not receiving
+// them fast enough.
+type OverflowPolicy int
+
+const (
+	// OverflowBlock stops reading from redis until there's room in the
+	// buffer, or PubSub is closed. Redis might disconnect clients that
+	// fall too far behind, see client-output-buffer-limit.
+	OverflowBlock OverflowPolicy = iota
+
+	// OverflowDrop discards new messages until there's room in the
+	// buffer. Other events are never discarded.
+	OverflowDrop
+)
+
+// Message is a message published to a channel, or to a sharded channel.
+type Message struct {
+	Channel string
+	Data    string
+}
+
+// PMessage is a message published to a channel that matches a pattern
+// subscription.
+type PMessage struct {
+	Pattern string
+	Channel string
+	Data    string
+}
+
+// Subscription confirms a subscribe or unsubscribe command.
+// Kind is the lowercase name of the command, e.g. "psubscribe", and Count
+// is the number of channels and patterns the connection is still
+// subscribed to.
+type Subscription struct {
+	Kind    string
+	Channel string
+	Count   int
+}
+
+// Pong is the reply to PubSub.Ping.
+type Pong struct {
+	Data string
+}
+
+// Reconnect is received when PubSub reconnects to a server after its
+// connection broke, and subscribes again to its channels and patterns.
+// Messages published while disconnected are lost.
+type Reconnect struct {
+	Addr     string        // address of the server
+	Err      error         // error that broke the connection
+	Downtime time.Duration // time spent disconnected
+	Attempts int           // number of connection attempts
+}
+
+// PubSubStats are the counters of PubSub.
+type PubSubStats struct {
+	Messages   uint64 // messages received from redis
+	Dropped    uint64 // messages discarded by OverflowDrop
+	Reconnects uint64 // successful reconnections
+}
+
+// PubSub is a set of subscriptions to channels and patterns.
+// Subscriptions can be added and removed at any time, and events are
+// received with Receive or Channel. It is safe for use by multiple
+// goroutines.
+//
+// Example:
+//
+//	ps := rc.NewPubSub()
+//	defer ps.Close()
+//	if err := ps.Subscribe("news", "weather"); err != nil {
+//		...
+//	}
+//	for {
+//		ev, err := ps.Receive()
+//		if err != nil {
+//			...
+//		}
+//		switch m := ev.(type) {
+//		case redis.Message:
+//			fmt.Println(m.Channel, m.Data)
+//		case redis.Subscription:
+//			...
+//		}
+//	}
+type PubSub struct {
+	// PingInterval is the interval between keepalive PINGs. The
+	// connection is considered dead if nothing is received from redis
+	// for this long after a PING. If zero, DefaultPingInterval is used.
+	// It must be set before subscribing.
+	PingInterval time.Duration
+
+	// Reconnect makes PubSub reconnect broken connections and subscribe
+	// again to their channels and patterns, instead of returning their
+	// errors. Reconnections are reported as Reconnect events.
+	// It must be set before subscribing.
+	Reconnect bool
+
+	// MinBackoff and MaxBackoff are the bounds of the exponential backoff
+	// between reconnection attempts. If zero, DefaultMinBackoff and
+	// DefaultMaxBackoff are used. They must be set before subscribing.
+	MinBackoff time.Duration
+	MaxBackoff time.Duration
+
+	// Overflow determines what to do with messages when the application
+	// is not receiving events fast enough. The default is OverflowBlock.
+	// It must be set before subscribing.
+	Overflow OverflowPolicy
+
+	c      *Client
+	lk     sync.Mutex
+	conns  map[string]*psConn
+	closed bool
+	done   chan bool
+	events chan interface{}
+	wg     sync.WaitGroup
+
+	slk   sync.Mutex
+	stats PubSubStats
+}
+
+// psConn is a connection of PubSub to a specific server.
+type psConn struct {
+	ps   *PubSub
+	srv  ServerInfo
+	cn   *conn
+	wlk  sync.Mutex // serializes writes
+	seen int32      // set when anything is read, for the keepalive
+	dead chan bool  // closed when the reader exits
+
+	// broken, channels, patterns and shards are guarded by ps.lk.
+	broken   bool
+	channels map[string]bool
+	patterns map[string]bool
+	shards   map[string]bool // sharded channels
+}
+
+// NewPubSub returns a PubSub with no subscriptions. Connections are
+// made on demand.
+func (c *Client) NewPubSub() *PubSub {
+	return &PubSub{
+		c:      c,
+		conns:  make(map[string]*psConn),
+		done:   make(chan bool),
+		events: make(chan interface{}, pubSubBufferSize),
+	}
+}
+
+func (ps *PubSub) pingInterval() time.Duration {
+	if ps.PingInterval != 0 {
+		return ps.PingInterval
+	}
+	return DefaultPingInterval
+}
+
+// Stats returns the counters of PubSub.
+func (ps *PubSub) Stats() PubSubStats {
+	ps.slk.Lock()
+	defer ps.slk.Unlock()
+	return ps.stats
+}
+
+// dial returns a new connection to srv. It must be registered in
+// ps.conns before being started.
+func (ps *PubSub) dial(srv ServerInfo) (*psConn, error) {
+	cn, err := ps.c.getConn(srv)
+	if err != nil {
+		return nil, err
+	}
+	// Subscribed connections only read when there are messages.
+	cn.nc.SetDeadline(time.Time{})
+	return &psConn{
+		ps:       ps,
+		srv:      srv,
+		cn:       cn,
+		dead:     make(chan bool),
+		channels: make(map[string]bool),
+		patterns: make(map[string]bool),
+		shards:   make(map[string]bool),
+	}, nil
+}
+
+// register adds pc to ps.conns, replacing any previous connection to the
+// same server. It must be called with ps.lk held, and followed by start.
+func (ps *PubSub) register(pc *psConn) {
+	ps.conns[serverID(pc.srv)] = pc
+	ps.wg.Add(1) // for readLoop, so Close waits for it
+}
+
+// start starts the goroutines of a registered connection.
+func (pc *psConn) start() {
+	go pc.readLoop()
+	go pc.pingLoop()
+}
+
+// missing returns the servers of groups that PubSub is not connected to.
+// It must be called with ps.lk held.
+func (ps *PubSub) missing(groups []*keyGroup) []ServerInfo {
+	var r []ServerInfo
+	seen := make(map[string]bool)
+	for _, g := range groups {
+		id := serverID(g.srv)
+		if _, ok := ps.conns[id]; !ok && !seen[id] {
+			seen[id] = true
+			r = append(r, g.srv)
+		}
+	}
+	return r
+}
+
+// connect connects to the given servers. They're dialed without holding
+// ps.lk, so a slow server does not block other subscriptions or readers.
+func (ps *PubSub) connect(servers []ServerInfo) error {
+	for _, srv := range servers {
+		pc, err := ps.dial(srv)
+		if err != nil {
+			return err
+		}
+		ps.lk.Lock()
+		if ps.closed {
+			ps.lk.Unlock()
+			pc.cn.nc.Close()
+			return ErrPubSubClosed
+		}
+		if _, ok := ps.conns[serverID(srv)]; ok {
+			// Connected by another goroutine meanwhile.
+			ps.lk.Unlock()
+			pc.cn.nc.Close()
+			continue
+		}
+		ps.register(pc)
+		ps.lk.Unlock()
+		pc.start()
+	}
+	return nil
+}
+
+// send sends a command to redis. Replies are handled by readLoop.
+func (pc *psConn) send(a ...interface{}) error {
+	pc.wlk.Lock()
+	defer pc.wlk.Unlock()
+	pc.cn.nc.SetWriteDeadline(time.Now().Add(pc.ps.c.netTimeout()))
+	err := pc.ps.c.send_urp(pc.cn.rw, a...)
+	if err != nil {
+		// Let readLoop deal with the broken connection.
+		pc.cn.nc.Close()
+		return err
+	}
+	pc.cn.nc.SetWriteDeadline(time.Time{})
+	return nil
+}
+
+// readLoop reads replies from redis and delivers them as events, until
+// the connection is closed.
+func (pc *psConn) readLoop() {
+	defer pc.ps.wg.Done()
+	defer close(pc.dead)
+	for {
+		v, err := pc.ps.c.parseResponse(pc.cn.rw.Reader)
+		if err != nil {
+			if _, ok := err.(Error); ok {
+				pc.ps.deliver(err)
+				continue
+			}
+			pc.ps.drop(pc, err)
+			return
+		}
+		atomic.StoreInt32(&pc.seen, 1)
+		var ev interface{}
+		if s, ok := v.(string); ok {
+			// Connections unsubscribed from everything reply to
+			// PING like regular connections, with its data.
+			ev = Pong{Data: s}
+		} else if ev, err = parsePubSubReply(v); err != nil {
+			pc.ps.deliver(err)
+			continue
+		}
+		if p, ok := ev.(Pong); ok && p.Data == pubSubKeepAlive {
+			continue
+		}
+		pc.ps.deliver(ev)
+	}
+}
+
+// pingLoop sends keepalive PINGs, and closes the connection if redis
+// does not reply to them.
+func (pc *psConn) pingLoop() {
+	t := time.NewTicker(pc.ps.pingInterval())
+	defer t.Stop()
+	pinged := false
+	for {
+		select {
+		case <-pc.dead:
+			return
+		case <-t.C:
+			if pinged && atomic.LoadInt32(&pc.seen) == 0 {
+				pc.cn.nc.Close()
+				return
+			}
+			atomic.StoreInt32(&pc.seen, 0)
+			if pc.send("PING", pubSubKeepAlive) != nil {
+				return
+			}
+			pinged = true
+		}
+	}
+}
+
+// deliver sends an event to the application, unless PubSub is closed.
+// Messages are discarded if the buffer is full and Overflow is
+// OverflowDrop.
+func (ps *PubSub) deliver(ev interface{}) {
+	switch ev.(type) {
+	case Message, PMessage:
+		ps.slk.Lock()
+		ps.stats.Messages++
+		ps.slk.Unlock()
+		if ps.Overflow == OverflowDrop {
+			select {
+			case ps.events <- ev:
+			default:
+				ps.slk.Lock()
+				ps.stats.Dropped++
+				ps.slk.Unlock()
+			}
+			return
+		}
+	}
+	select {
+	case ps.events <- ev:
+	case <-ps.done:
+	}
+}
+
+// drop handles a broken connection. If Reconnect is set, it starts
+// reconnecting to the server. Otherwise the connection and its
+// subscriptions are removed, and err is reported to the application.
+func (ps *PubSub) drop(pc *psConn, err error) {
+	pc.cn.nc.Close()
+	ps.lk.Lock()
+	if ps.closed {
+		ps.lk.Unlock()
+		return
+	}
+	if ps.Reconnect {
+		pc.broken = true
+		ps.lk.Unlock()
+		go ps.reconnect(pc, err)
+		return
+	}
+	if ps.conns[serverID(pc.srv)] == pc {
+		delete(ps.conns, serverID(pc.srv))
+	}
+	ps.lk.Unlock()
+	ps.deliver(err)
+}
+
+// reconnect connects to the server of a broken connection, waiting longer
+// after each failed attempt, and subscribes again to its channels and
+// patterns. Subscriptions changed while disconnected are kept by the
+// broken connection until it's replaced.
+func (ps *PubSub) reconnect(old *psConn, cause error) {
+	start := time.Now()
+	backoff := ps.MinBackoff
+	if backoff <= 0 {
+		backoff = DefaultMinBackoff
+	}
+	max := ps.MaxBackoff
+	if max <= 0 {
+		max = DefaultMaxBackoff
+	}
+	for attempt := 1; ; attempt++ {
+		select {
+		case <-ps.done:
+			return
+		case <-time.After(backoff):
+		}
+		if backoff *= 2; backoff > max {
+			backoff = max
+		}
+		pc, err := ps.dial(old.srv)
+		if err != nil {
+			continue
+		}
+		ps.lk.Lock()
+		if ps.closed {
+			ps.lk.Unlock()
+			pc.cn.nc.Close()
+			return
+		}
+		for name := range old.channels {
+			pc.channels[name] = true
+		}
+		for name := range old.patterns {
+			pc.patterns[name] = true
+		}
+		for name := range old.shards {
+			pc.shards[name] = true
+		}
+		ps.register(pc)
+		ps.lk.Unlock()
+
+		ps.slk.Lock()
+		ps.stats.Reconnects++
+		ps.slk.Unlock()
+		ps.deliver(Reconnect{
+			Addr:     old.srv.Addr.String(),
+			Err:      cause,
+			Downtime: time.Since(start),
+			Attempts: attempt,
+		})
+
+		// Errors are handled by readLoop, which reconnects again.
+		pc.start()
+		ps.lk.Lock()
+		if len(pc.channels) > 0 {
+			pc.send(append([]interface{}{"SUBSCRIBE"}, setNames(pc.channels)...)...)
+		}
+		if len(pc.patterns) > 0 {
+			pc.send(append([]interface{}{"PSUBSCRIBE"}, setNames(pc.patterns)...)...)
+		}
+		if len(pc.shards) > 0 {
+			pc.send(append([]interface{}{"SSUBSCRIBE"}, setNames(pc.shards)...)...)
+		}
+		ps.lk.Unlock()
+		return
+	}
+}
+
+// setNames returns the names of a set of channels or patterns as command
+// arguments.
+func setNames(set map[string]bool) []interface{} {
+	a := make([]interface{}, 0, len(set))
+	for name := range set {
+		a = append(a, name)
+	}
+	return a
+}
+
+// parsePubSubReply converts a reply received by a subscribed connection
+// to an event.
+func parsePubSubReply(v interface{}) (interface{}, error) {
+	r, ok := v.([]interface{})
+	if !ok || len(r) < 2 {
+		return nil, ErrServerError
+	}
+	str := func(a interface{}) string {
+		s, _ := a.(string)
+		return s
+	}
+	switch kind := strings.ToLower(str(r[0])); kind {
+	case "message", "smessage":
+		if len(r) == 3 {
+			return Message{Channel: str(r[1]), Data: str(r[2])}, nil
+		}
+	case "pmessage":
+		if len(r) == 4 {
+			return PMessage{
+				Pattern: str(r[1]),
+				Channel: str(r[2]),
+				Data:    str(r[3]),
+			}, nil
+		}
+	case "subscribe", "unsubscribe", "psubscribe", "punsubscribe",
+		"ssubscribe", "sunsubscribe":
+		if len(r) == 3 {
+			if n, ok := r[2].(int); ok {
+				return Subscription{Kind: kind, Channel: str(r[1]), Count: n}, nil
+			}
+		}
+	case "pong":
+		return Pong{Data: str(r[1])}, nil
+	}
+	return nil, ErrServerError
+}
+
+// route groups channels or patterns by the server they're subscribed on.
+// Channels are routed by name, like Publish does, and patterns are
+// subscribed once on every redis instance because they can match any
+// channel, and channels are not bound to databases.
+func (ps *PubSub) route(cmd string, names []string) ([]*keyGroup, error) {
+	switch cmd {
+	case "PSUBSCRIBE", "PUNSUBSCRIBE":
+		servers, err := ps.c.instances()
+		if err != nil {
+			return nil, err
+		}
+		groups := make([]*keyGroup, len(servers))
+		for n, srv := range servers {
+			groups[n] = &keyGroup{srv: srv, keys: names}
+		}
+		return groups, nil
+	}
+	return ps.c.groupKeys(cmd, names)
+}
+
+// subscribe sends a subscribe command for the given channels or patterns
+// to the servers they're routed to, and keeps track of them in set.
+func (ps *PubSub) subscribe(cmd string, names []string, set func(pc *psConn) map[string]bool) error {
+	groups, err := ps.route(cmd, names)
+	if err != nil {
+		return err
+	}
+	return ps.subscribeGroups(cmd, groups, set)
+}
+
+// subscribeGroups sends a subscribe command for the channels or patterns
+// of each group to the server of the group.
+func (ps *PubSub) subscribeGroups(cmd string, groups []*keyGroup, set func(pc *psConn) map[string]bool) error {
+	ps.lk.Lock()
+	for {
+		if ps.closed {
+			ps.lk.Unlock()
+			return ErrPubSubClosed
+		}
+		missing := ps.missing(groups)
+		if len(missing) == 0 {
+			break
+		}
+		ps.lk.Unlock()
+		if err := ps.connect(missing); err != nil {
+			return err
+		}
+		ps.lk.Lock()
+	}
+	defer ps.lk.Unlock()
+	for _, g := range groups {
+		pc := ps.conns[serverID(g.srv)]
+		for _, name := range g.keys {
+			set(pc)[name] = true
+		}
+		if pc.broken {
+			// Subscribed when reconnected.
+			continue
+		}
+		if err := pc.send(append([]interface{}{cmd}, vstr2iface(g.keys)...)...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// unsubscribe sends an unsubscribe command for the given channels or
+// patterns, or all of them if none is given.
+func (ps *PubSub) unsubscribe(cmd string, names []string, set func(pc *psConn) map[string]bool) error {
+	var groups []*keyGroup
+	if len(names) > 0 {
+		var err error
+		if groups, err = ps.route(cmd, names); err != nil {
+			return err
+		}
+	}
+	return ps.unsubscribeGroups(cmd, groups, set)
+}
+
+// unsubscribeGroups sends an unsubscribe command for the channels or
+// patterns of each group to the server of the group. If groups is empty,
+// every server is unsubscribed from all of them.
+func (ps *PubSub) unsubscribeGroups(cmd string, groups []*keyGroup, set func(pc *psConn) map[string]bool) error {
+	ps.lk.Lock()
+	defer ps.lk.Unlock()
+	if ps.closed {
+		return ErrPubSubClosed
+	}
+	if len(groups) == 0 {
+		for _, pc := range ps.conns {
+			if len(set(pc)) > 0 {
+				groups = append(groups, &keyGroup{srv: pc.srv})
+			}
+		}
+	}
+	for _, g := range groups {
+		pc, ok := ps.conns[serverID(g.srv)]
+		if !ok {
+			continue
+		}
+		m := set(pc)
+		if len(g.keys) == 0 {
+			for name := range m {
+				delete(m, name)
+			}
+		}
+		for _, name := range g.keys {
+			delete(m, name)
+		}
+		if pc.broken {
+			continue
+		}
+		err := pc.send(append([]interface{}{cmd}, vstr2iface(g.keys)...)...)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func channelsOf(pc *psConn) map[string]bool {
+	return pc.channels
+}
+
+func patternsOf(pc *psConn) map[string]bool {
+	return pc.patterns
+}
+
+func shardsOf(pc *psConn) map[string]bool {
+	return pc.shards
+}
+
+// http://redis.io/commands/subscribe
+// Subscribe subscribes to the given channels. Each subscription is
+// confirmed by a Subscription event.
+//
+// On sharded connections, channels are subscribed on the server selected
+// by their name, which is where Publish sends their messages.
+func (ps *PubSub) Subscribe(channels ...string) error {
+	return ps.subscribe("SUBSCRIBE", channels, channelsOf)
+}
+
+// http://redis.io/commands/unsubscribe
+// Unsubscribe unsubscribes from the given channels, or from all channels
+// if none is given.
+func (ps *PubSub) Unsubscribe(channels ...string) error {
+	return ps.unsubscribe("UNSUBSCRIBE", channels, channelsOf)
+}
+
+// http://redis.io/commands/psubscribe
+// PSubscribe subscribes to the given glob-style patterns. Messages
+// published to channels that match them are received as PMessage.
+//
+// On sharded connections, patterns are subscribed on every redis instance.
+func (ps *PubSub) PSubscribe(patterns ...string) error {
+	return ps.subscribe("PSUBSCRIBE", patterns, patternsOf)
+}
+
+// http://redis.io/commands/punsubscribe
+// PUnsubscribe unsubscribes from the given patterns, or from all patterns
+// if none is given.
+func (ps *PubSub) PUnsubscribe(patterns ...string) error {
+	return ps.unsubscribe("PUNSUBSCRIBE", patterns, patternsOf)
+}
+
+// http://redis.io/commands/ssubscribe
+// SSubscribe subscribes to the given sharded channels, which requires
+// redis 7.0 or newer. Messages are received as Message events.
+// Channels are routed like Subscribe does.
+func (ps *PubSub) SSubscribe(channels ...string) error {
+	return ps.subscribe("SSUBSCRIBE", channels, shardsOf)
+}
+
+// http://redis.io/commands/sunsubscribe
+// SUnsubscribe unsubscribes from the given sharded channels, or from all
+// sharded channels if none is given.
+func (ps *PubSub) SUnsubscribe(channels ...string) error {
+	return ps.unsubscribe("SUNSUBSCRIBE", channels, shardsOf)
+}
+
+// http://redis.io/commands/ping
+// Ping sends a PING with the given data on every connection. Redis
+// replies with a Pong event for each one of them.
+func (ps *PubSub) Ping(data string) error {
+	ps.lk.Lock()
+	defer ps.lk.Unlock()
+	if ps.closed {
+		return ErrPubSubClosed
+	}
+	for _, pc := range ps.conns {
+		if pc.broken {
+			continue
+		}
+		if err := pc.send("PING", data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Receive waits for the next event, which is one of Message, PMessage,
+// Subscription, Pong or Reconnect. Errors are returned as they happen,
+// and a broken connection loses its subscriptions unless Reconnect is set.
+// Receive returns ErrPubSubClosed after Close is called.
+func (ps *PubSub) Receive() (interface{}, error) {
+	ev, ok := <-ps.events
+	if !ok {
+		return nil, ErrPubSubClosed
+	}
+	if err, ok := ev.(error); ok {
+		return nil, err
+	}
+	return ev, nil
+}
+
+// Channel returns the channel of events received by Receive, including
+// errors. It's closed after Close is called.
+func (ps *PubSub) Channel() <-chan interface{} {
+	return ps.events
+}
+
+// Close closes all connections of PubSub.
+func (ps *PubSub) Close() error {
+	ps.lk.Lock()
+	defer ps.lk.Unlock()
+	if ps.closed {
+		return nil
+	}
+	ps.closed = true
+	close(ps.done)
+	for _, pc := range ps.conns {
+		pc.cn.nc.Close()
+	}
+	go func() {
+		ps.wg.Wait()
+		close(ps.events)
+	}()
+	return nil
+}
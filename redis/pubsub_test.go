@@ -63,7 +63,7 @@ func TestPubSub(t *testing.T) {
 
 	go func() {
 		for i := counter; i > 0; i-- {
-			err := rcPubSub.Publish(k, v)
+			_, err := rcPubSub.Publish(k, v)
 			if err != nil {
 				t.Error(err)
 				return
@@ -79,3 +79,244 @@ func TestPubSub(t *testing.T) {
 		t.Fatal("Failed to parse PubSub messages ", counter)
 	}
 }
+
+func TestParsePubSubReply(t *testing.T) {
+	tests := []struct {
+		reply interface{}
+		event interface{}
+	}{
+		{[]interface{}{"message", "ch", "hi"}, Message{"ch", "hi"}},
+		{[]interface{}{"pmessage", "c*", "ch", "hi"}, PMessage{"c*", "ch", "hi"}},
+		{[]interface{}{"subscribe", "ch", 1}, Subscription{"subscribe", "ch", 1}},
+		{[]interface{}{"unsubscribe", nil, 0}, Subscription{"unsubscribe", "", 0}},
+		{[]interface{}{"pong", ""}, Pong{""}},
+	}
+	for _, test := range tests {
+		if ev, err := parsePubSubReply(test.reply); err != nil {
+			t.Fatal(err)
+		} else if ev != test.event {
+			t.Fatalf(errUnexpected, ev)
+		}
+	}
+	for _, reply := range []interface{}{"OK", []interface{}{"subscribe", "ch"}} {
+		if _, err := parsePubSubReply(reply); err != ErrServerError {
+			t.Fatalf(errUnexpected, err)
+		}
+	}
+}
+
+// receiveEvent returns the next event that is not a Subscription.
+func receiveEvent(t *testing.T, ps *PubSub) interface{} {
+	for {
+		ev, err := ps.Receive()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, ok := ev.(Subscription); !ok {
+			return ev
+		}
+	}
+}
+
+func TestPubSubChannelsAndPatterns(t *testing.T) {
+	k := randomString(16)
+	ps := rcPubSub.NewPubSub()
+	defer ps.Close()
+	if err := ps.Subscribe(k+".a", k+".b"); err != nil {
+		t.Fatal(err)
+	}
+	if err := ps.PSubscribe(k + ".p.*"); err != nil {
+		t.Fatal(err)
+	}
+	for n := 0; n < 3; n++ {
+		ev, err := ps.Receive()
+		if err != nil {
+			t.Fatal(err)
+		} else if s, ok := ev.(Subscription); !ok || s.Count != n+1 {
+			t.Fatalf(errUnexpected, ev)
+		}
+	}
+	rcPubSub.Publish(k+".b", "hello")
+	if ev := receiveEvent(t, ps); ev != (Message{k + ".b", "hello"}) {
+		t.Fatalf(errUnexpected, ev)
+	}
+	rcPubSub.Publish(k+".p.1", "world")
+	if ev := receiveEvent(t, ps); ev != (PMessage{k + ".p.*", k + ".p.1", "world"}) {
+		t.Fatalf(errUnexpected, ev)
+	}
+	if err := ps.Unsubscribe(); err != nil {
+		t.Fatal(err)
+	}
+	if err := ps.Ping("hi"); err != nil {
+		t.Fatal(err)
+	}
+	if ev := receiveEvent(t, ps); ev != (Pong{"hi"}) {
+		t.Fatalf(errUnexpected, ev)
+	}
+	ps.Close()
+	if _, err := ps.Receive(); err != ErrPubSubClosed {
+		t.Fatalf(errUnexpected, err)
+	}
+	if err := ps.Subscribe(k); err != ErrPubSubClosed {
+		t.Fatalf(errUnexpected, err)
+	}
+}
+
+// TestPubSubUnsubscribedPing checks that keepalive PINGs of connections
+// unsubscribed from everything, which get regular replies, are not errors.
+func TestPubSubUnsubscribedPing(t *testing.T) {
+	ps := rcPubSub.NewPubSub()
+	ps.PingInterval = 10 * time.Millisecond
+	defer ps.Close()
+	if err := ps.Subscribe(randomString(16)); err != nil {
+		t.Fatal(err)
+	}
+	if err := ps.Unsubscribe(); err != nil {
+		t.Fatal(err)
+	}
+	for n := 0; n < 2; n++ {
+		if _, err := ps.Receive(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	time.Sleep(50 * time.Millisecond)
+	if err := ps.Ping("hi"); err != nil {
+		t.Fatal(err)
+	}
+	if ev := receiveEvent(t, ps); ev != (Pong{"hi"}) {
+		t.Fatalf(errUnexpected, ev)
+	}
+}
+
+// TestPubSubReconnect breaks the connection of a subscriber and checks
+// that it subscribes again.
+func TestPubSubReconnect(t *testing.T) {
+	k := randomString(16)
+	ps := rcPubSub.NewPubSub()
+	ps.Reconnect = true
+	ps.MinBackoff = 10 * time.Millisecond
+	defer ps.Close()
+	if err := ps.Subscribe(k); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ps.Receive(); err != nil {
+		t.Fatal(err)
+	}
+	ps.lk.Lock()
+	for _, pc := range ps.conns {
+		pc.cn.nc.Close()
+	}
+	ps.lk.Unlock()
+	if ev, ok := receiveEvent(t, ps).(Reconnect); !ok || ev.Attempts != 1 {
+		t.Fatalf(errUnexpected, ev)
+	}
+	if ev, err := ps.Receive(); err != nil {
+		t.Fatal(err)
+	} else if ev != (Subscription{"subscribe", k, 1}) {
+		t.Fatalf(errUnexpected, ev)
+	}
+	rcPubSub.Publish(k, "hello")
+	if ev := receiveEvent(t, ps); ev != (Message{k, "hello"}) {
+		t.Fatalf(errUnexpected, ev)
+	}
+	if stats := ps.Stats(); stats.Reconnects != 1 {
+		t.Fatalf(errUnexpected, stats)
+	}
+}
+
+// TestPubSubOverflowDrop publishes more messages than PubSub can buffer.
+func TestPubSubOverflowDrop(t *testing.T) {
+	k := randomString(16)
+	ps := rcPubSub.NewPubSub()
+	ps.Overflow = OverflowDrop
+	defer ps.Close()
+	if err := ps.Subscribe(k); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ps.Receive(); err != nil {
+		t.Fatal(err)
+	}
+	n := pubSubBufferSize * 2
+	for i := 0; i < n; i++ {
+		rcPubSub.Publish(k, "hello")
+	}
+	for i := 0; ps.Stats().Messages < uint64(n); i++ {
+		if i == 100 {
+			t.Fatalf(errUnexpected, ps.Stats())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if stats := ps.Stats(); stats.Dropped != uint64(n-pubSubBufferSize) {
+		t.Fatalf(errUnexpected, stats)
+	}
+}
+
+// TestPubSubSharded checks that channels are subscribed on the same server
+// they're published to.
+func TestPubSubSharded(t *testing.T) {
+	c := New("127.0.0.1:6379", "127.0.0.1:6379 db=1")
+	ps := c.NewPubSub()
+	defer ps.Close()
+	channels := make([]string, 8)
+	for n := range channels {
+		channels[n] = randomString(16)
+	}
+	if err := ps.Subscribe(channels...); err != nil {
+		t.Fatal(err)
+	}
+	for _ = range channels {
+		if _, err := ps.Receive(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	ps.lk.Lock()
+	for _, pc := range ps.conns {
+		for ch := range pc.channels {
+			srv, _ := c.selector.PickServer(ch)
+			if serverID(srv) != serverID(pc.srv) {
+				t.Fatalf(errUnexpected, ch)
+			}
+		}
+	}
+	ps.lk.Unlock()
+	counts, err := c.PubSubNumSub(channels...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, ch := range channels {
+		if counts[ch] != 1 {
+			t.Fatalf(errUnexpected, counts)
+		}
+	}
+	if n, err := c.Publish(channels[0], "hello"); err != nil {
+		t.Fatal(err)
+	} else if n != 1 {
+		t.Fatalf(errUnexpected, n)
+	}
+	if ev := receiveEvent(t, ps); ev != (Message{channels[0], "hello"}) {
+		t.Fatalf(errUnexpected, ev)
+	}
+}
+
+func TestPubSubIntrospection(t *testing.T) {
+	k := randomString(16)
+	ps := rcPubSub.NewPubSub()
+	defer ps.Close()
+	ps.Subscribe(k)
+	ps.PSubscribe(k + ".*")
+	for n := 0; n < 2; n++ {
+		if _, err := ps.Receive(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if channels, err := rcPubSub.PubSubChannels(k); err != nil {
+		t.Fatal(err)
+	} else if len(channels) != 1 || channels[0] != k {
+		t.Fatalf(errUnexpected, channels)
+	}
+	if n, err := rcPubSub.PubSubNumPat(); err != nil {
+		t.Fatal(err)
+	} else if n < 1 {
+		t.Fatalf(errUnexpected, n)
+	}
+}
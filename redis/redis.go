@@ -25,6 +25,7 @@ import (
 	"fmt"
 	"io"
 	"net"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
@@ -43,8 +44,23 @@ var (
 
 	// ErrTimedOut is returned when a Read or Write operation times out
 	ErrTimedOut = errors.New("timed out")
+
+	// ErrNil is returned when redis replies with nil, e.g. by ZScore
+	// for a member that does not exist.
+	ErrNil = errors.New("nil reply")
+
+	// ErrCrossShard is returned by commands whose keys must be on the
+	// same server, but are not.
+	ErrCrossShard = errors.New("keys are not on the same server")
 )
 
+// Error is an error reply from redis, e.g. "ERR unknown command".
+type Error string
+
+func (e Error) Error() string {
+	return string(e)
+}
+
 // DefaultTimeout is the default socket read/write timeout.
 const DefaultTimeout = time.Duration(200) * time.Millisecond
 
@@ -107,7 +123,7 @@ type conn struct {
 
 // release returns this connection back to the client's free pool
 func (cn *conn) release() {
-	cn.c.putFreeConn(cn.srv.Addr, cn)
+	cn.c.putFreeConn(cn.srv, cn)
 }
 
 func (cn *conn) extendDeadline(delta time.Duration) {
@@ -125,19 +141,21 @@ func (cn *conn) condRelease(err *error) {
 	}
 }
 
-func (c *Client) putFreeConn(addr net.Addr, cn *conn) {
+// Free connections are kept per server and database, because
+// connections to the same address may have selected different databases.
+func (c *Client) putFreeConn(srv ServerInfo, cn *conn) {
 	c.lk.Lock()
 	defer c.lk.Unlock()
 	if c.freeconn == nil {
 		c.freeconn = make(map[string][]*conn)
 	}
-	freelist := c.freeconn[addr.String()]
+	freelist := c.freeconn[serverID(srv)]
 	if len(freelist) >= MaxIdleConnsPerAddr {
 		cn.nc.Close()
 		return
 	}
 	cn.nc.SetDeadline(time.Time{}) // no deadline
-	c.freeconn[addr.String()] = append(freelist, cn)
+	c.freeconn[serverID(srv)] = append(freelist, cn)
 }
 
 func (c *Client) getFreeConn(srv ServerInfo) (cn *conn, ok bool) {
@@ -146,12 +164,12 @@ func (c *Client) getFreeConn(srv ServerInfo) (cn *conn, ok bool) {
 	if c.freeconn == nil {
 		return nil, false
 	}
-	freelist, ok := c.freeconn[srv.Addr.String()]
+	freelist, ok := c.freeconn[serverID(srv)]
 	if !ok || len(freelist) == 0 {
 		return nil, false
 	}
 	cn = freelist[len(freelist)-1]
-	c.freeconn[srv.Addr.String()] = freelist[:len(freelist)-1]
+	c.freeconn[serverID(srv)] = freelist[:len(freelist)-1]
 	return cn, true
 }
 
@@ -212,26 +230,39 @@ func (c *Client) getConn(srv ServerInfo) (*conn, error) {
 	cn = &conn{
 		nc:  nc,
 		srv: srv,
-		rw:  c.notifyClose(nc),
+		rw:  c.notifyClose(srv, nc),
 		c:   c,
 	}
 	cn.extendDeadline(0)
-	if srv.Passwd != "" {
-		_, err := c.execute_urp(cn.rw, "AUTH", srv.Passwd)
-		if err != nil {
-			return nil, err
+	if err = c.handshake(cn); err != nil {
+		nc.Close()
+		return nil, err
+	}
+	return cn, nil
+}
+
+// handshake authenticates a new connection, and selects its db and
+// read-only mode, as set in its server options.
+func (c *Client) handshake(cn *conn) error {
+	if cn.srv.Passwd != "" {
+		if _, err := c.execute_urp(cn.rw, "AUTH", cn.srv.Passwd); err != nil {
+			return err
 		}
 	}
-	if srv.DB != "" {
-		_, err := c.execute(cn.rw, "SELECT", srv.DB)
-		if err != nil {
-			return nil, err
+	if cn.srv.DB != "" {
+		if _, err := c.execute(cn.rw, "SELECT", cn.srv.DB); err != nil {
+			return err
 		}
 	}
-	return cn, nil
+	if cn.srv.ReadOnly {
+		if _, err := c.execute_urp(cn.rw, "READONLY"); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
-func (c *Client) notifyClose(nc net.Conn) *bufio.ReadWriter {
+func (c *Client) notifyClose(srv ServerInfo, nc net.Conn) *bufio.ReadWriter {
 	pr, pw := io.Pipe()
 	rw := bufio.NewReadWriter(bufio.NewReader(pr), bufio.NewWriter(nc))
 	go func() {
@@ -240,18 +271,18 @@ func (c *Client) notifyClose(nc net.Conn) *bufio.ReadWriter {
 			err = io.EOF
 		}
 		pw.CloseWithError(err)
-		c.cleanupFreeConn(nc)
+		c.cleanupFreeConn(srv, nc)
 	}()
 	return rw
 }
 
-func (c *Client) cleanupFreeConn(nc net.Conn) {
+func (c *Client) cleanupFreeConn(srv ServerInfo, nc net.Conn) {
 	c.lk.Lock()
 	defer c.lk.Unlock()
 	if c.freeconn == nil {
 		return
 	}
-	freelist, ok := c.freeconn[nc.RemoteAddr().String()]
+	freelist, ok := c.freeconn[serverID(srv)]
 	if !ok || len(freelist) == 0 {
 		return
 	}
@@ -262,46 +293,349 @@ func (c *Client) cleanupFreeConn(nc net.Conn) {
 			copy(freelist[n:], freelist[n+1:])
 			freelist[len(freelist)-1] = nil
 			freelist = freelist[:len(freelist)-1]
-			c.freeconn[nc.RemoteAddr().String()] = freelist
+			c.freeconn[serverID(srv)] = freelist
 			break
 		}
 	}
 }
 
+// readOnlyCommands are the commands that can be served by replicas.
+var readOnlyCommands = map[string]bool{
+	"BITCOUNT": true, "BITFIELD_RO": true, "BITPOS": true, "DUMP": true,
+	"EVAL_RO": true, "EVALSHA_RO": true, "EXISTS": true, "EXPIRETIME": true,
+	"FCALL_RO": true, "GEODIST": true, "GEOHASH": true, "GEOPOS": true,
+	"GEOSEARCH": true, "GET": true, "GETBIT": true, "GETRANGE": true,
+	"HEXISTS": true, "HEXPIRETIME": true, "HGET": true, "HGETALL": true,
+	"HKEYS": true, "HLEN": true, "HMGET": true, "HPTTL": true,
+	"HRANDFIELD": true, "HSCAN": true, "HSTRLEN": true, "HTTL": true,
+	"HVALS": true, "LINDEX": true, "LLEN": true, "LPOS": true,
+	"LRANGE": true, "MGET": true, "OBJECT": true, "PEXPIRETIME": true,
+	"PFCOUNT": true, "PTTL": true, "SCARD": true, "SDIFF": true,
+	"SINTER": true, "SINTERCARD": true, "SISMEMBER": true, "SMEMBERS": true,
+	"SMISMEMBER": true, "SORT_RO": true, "SRANDMEMBER": true, "SSCAN": true,
+	"STRLEN": true, "SUBSTR": true, "SUNION": true, "TOUCH": true,
+	"TTL": true, "TYPE": true, "XINFO": true, "XLEN": true,
+	"XPENDING": true, "XRANGE": true, "XREAD": true, "XREVRANGE": true,
+	"ZCARD": true, "ZCOUNT": true, "ZDIFF": true, "ZINTER": true,
+	"ZINTERCARD": true, "ZLEXCOUNT": true, "ZMSCORE": true, "ZRANDMEMBER": true,
+	"ZRANGE": true, "ZRANGEBYLEX": true, "ZRANGEBYSCORE": true, "ZRANK": true,
+	"ZREVRANGE": true, "ZREVRANGEBYLEX": true, "ZREVRANGEBYSCORE": true, "ZREVRANK": true,
+	"ZSCAN": true, "ZSCORE": true, "ZUNION": true,
+}
+
+// isReadOnly returns true if cmd can be served by a replica.
+func isReadOnly(cmd string) bool {
+	return readOnlyCommands[strings.ToUpper(cmd)]
+}
+
+// pickServer picks a server based on the key. Read-only commands are
+// routed through the selector's PickReadServer if it implements
+// ReadSelector, otherwise all commands go to PickServer.
+func (c *Client) pickServer(cmd, key string) (ServerInfo, error) {
+	if rs, ok := c.selector.(ReadSelector); ok && isReadOnly(cmd) {
+		return rs.PickReadServer(key)
+	}
+	return c.selector.PickServer(key)
+}
+
 // execWithKey picks a server based on the key, and executes a command in redis.
 func (c *Client) execWithKey(urp bool, cmd, key string, a ...interface{}) (v interface{}, err error) {
-	srv, err := c.selector.PickServer(key)
+	srv, err := c.pickServer(cmd, key)
 	if err != nil {
 		return
 	}
+	if lo, ok := c.selector.(latencyObserver); ok && isReadOnly(cmd) {
+		defer func(start time.Time) {
+			d := time.Since(start)
+			if err != nil {
+				// Penalize failing servers so they're not
+				// picked again for being fast.
+				d = c.netTimeout()
+			}
+			lo.ObserveLatency(srv, d)
+		}(time.Now())
+	}
 	x := []interface{}{cmd, key}
 	return c.execWithAddr(urp, srv, append(x, a...)...)
 }
 
-// execWithKeyTimeout picks a server based on the key, and executes a command
-// in redis, extending the connection timeout for the given command.
-func (c *Client) execWithKeyTimeout(urp bool, timeout int, cmd, key string, a ...interface{}) (v interface{}, err error) {
-	srv, err := c.selector.PickServer(key)
+// keyGroup is a set of keys bound to the same server, and their position
+// in the list of keys given by the caller.
+type keyGroup struct {
+	srv  ServerInfo
+	keys []string
+	idx  []int
+}
+
+// serverID identifies a redis database by its address and dbid, in the
+// format of server strings, e.g. "127.0.0.1:6379 db=1".
+func serverID(srv ServerInfo) string {
+	if srv.DB == "" {
+		return srv.Addr.String()
+	}
+	return srv.Addr.String() + " db=" + srv.DB
+}
+
+// serverAddr identifies a redis instance by its address.
+func serverAddr(srv ServerInfo) string {
+	return srv.Addr.String()
+}
+
+// groupKeys groups keys by the server picked for each of them to run cmd.
+// Groups and the keys in each group are kept in the same order of keys.
+//
+// Keys are grouped by their primary server, and read-only commands then
+// pick one read server per group, since picking a replica for each key
+// would split the keys of a shard among its replicas.
+func (c *Client) groupKeys(cmd string, keys []string) ([]*keyGroup, error) {
+	var groups []*keyGroup
+	m := make(map[string]*keyGroup)
+	for n, key := range keys {
+		srv, err := c.selector.PickServer(key)
+		if err != nil {
+			return nil, err
+		}
+		id := serverID(srv)
+		g, ok := m[id]
+		if !ok {
+			g = &keyGroup{srv: srv}
+			m[id] = g
+			groups = append(groups, g)
+		}
+		g.keys = append(g.keys, key)
+		g.idx = append(g.idx, n)
+	}
+	if rs, ok := c.selector.(ReadSelector); ok && isReadOnly(cmd) {
+		for _, g := range groups {
+			srv, err := rs.PickReadServer(g.keys[0])
+			if err != nil {
+				return nil, err
+			}
+			g.srv = srv
+		}
+	}
+	return groups, nil
+}
+
+// pickServerForKeys picks the server of a command whose keys must all be
+// on the same server, or returns ErrCrossShard.
+func (c *Client) pickServerForKeys(cmd string, keys []string) (ServerInfo, error) {
+	groups, err := c.groupKeys(cmd, keys)
 	if err != nil {
-		return
+		return ServerInfo{}, err
 	}
-	x := []interface{}{cmd, key}
-	return c.execWithAddrTimeout(urp, srv, timeout, append(x, a...)...)
+	switch len(groups) {
+	case 0:
+		return c.pickServer(cmd, "")
+	case 1:
+		return groups[0].srv, nil
+	}
+	return ServerInfo{}, ErrCrossShard
 }
 
-// execWithKeys calls execWithKey for each key, returns an array of results.
-func (c *Client) execWithKeys(urp bool, cmd string, keys []string, a ...interface{}) (v interface{}, err error) {
-	var r []interface{}
-	for _, k := range keys {
-		if tmp, e := c.execWithKey(urp, cmd, k, a...); e != nil {
+// execWithKeysTimeout executes a command whose keys must all be on the same
+// server, extending the connection timeout for the given command. The
+// command name is a[0].
+func (c *Client) execWithKeysTimeout(urp bool, timeout time.Duration, keys []string, a ...interface{}) (interface{}, error) {
+	srv, err := c.pickServerForKeys(a[0].(string), keys)
+	if err != nil {
+		return nil, err
+	}
+	return c.execWithAddrTimeout(urp, srv, timeout, a...)
+}
+
+// execOnGroups calls fn for each group concurrently, and returns the
+// first error returned by fn, if any.
+func (c *Client) execOnGroups(groups []*keyGroup, fn func(g *keyGroup) error) error {
+	if len(groups) == 1 {
+		return fn(groups[0])
+	}
+	ch := make(chan error, len(groups))
+	for _, g := range groups {
+		go func(g *keyGroup) {
+			ch <- fn(g)
+		}(g)
+	}
+	var err error
+	for n := 0; n < len(groups); n++ {
+		if e := <-ch; e != nil && err == nil {
 			err = e
-			return
-		} else {
-			r = append(r, tmp)
 		}
 	}
-	v = r
-	return
+	return err
+}
+
+// execSumWithKeys executes a multi-key command that returns an integer,
+// e.g. DEL, on every server that holds any of the keys, and returns the
+// sum of the results.
+func (c *Client) execSumWithKeys(cmd string, keys []string) (int, error) {
+	groups, err := c.groupKeys(cmd, keys)
+	if err != nil {
+		return 0, err
+	}
+	var lk sync.Mutex
+	sum := 0
+	err = c.execOnGroups(groups, func(g *keyGroup) error {
+		a := append([]interface{}{cmd}, vstr2iface(g.keys)...)
+		v, err := c.execWithAddr(true, g.srv, a...)
+		if err != nil {
+			return err
+		}
+		n, err := iface2int(v)
+		if err != nil {
+			return err
+		}
+		lk.Lock()
+		sum += n
+		lk.Unlock()
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return sum, nil
+}
+
+// Servers returns the list of servers the client is connected to, without
+// duplicates. If the client's ServerSelector does not implement
+// ServerIterator, only the first server is returned.
+func (c *Client) Servers() ([]ServerInfo, error) {
+	si, ok := c.selector.(ServerIterator)
+	if !ok {
+		srv, err := c.selector.PickServer("")
+		if err != nil {
+			return nil, err
+		}
+		return []ServerInfo{srv}, nil
+	}
+	var servers []ServerInfo
+	seen := make(map[string]bool)
+	err := si.Each(func(srv ServerInfo) error {
+		if id := serverID(srv); !seen[id] {
+			seen[id] = true
+			servers = append(servers, srv)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(servers) == 0 {
+		return nil, ErrNoServers
+	}
+	return servers, nil
+}
+
+// instances returns the servers of the client with distinct addresses, for
+// commands that affect a whole redis instance instead of one of its
+// databases, such as BGSAVE and CONFIG SET.
+func (c *Client) instances() ([]ServerInfo, error) {
+	servers, err := c.Servers()
+	if err != nil {
+		return nil, err
+	}
+	var r []ServerInfo
+	seen := make(map[string]bool)
+	for _, srv := range servers {
+		if addr := srv.Addr.String(); !seen[addr] {
+			seen[addr] = true
+			r = append(r, srv)
+		}
+	}
+	return r, nil
+}
+
+// ServerErrors is returned by commands that run on every server when some
+// of them fail. It maps servers to their errors. Servers are identified
+// like in server strings, by their address and db if set, e.g.
+// "127.0.0.1:6379 db=1", or only by their address for commands that run
+// once per redis instance, such as ConfigSet.
+type ServerErrors map[string]error
+
+func (e ServerErrors) Error() string {
+	addrs := make([]string, 0, len(e))
+	for addr := range e {
+		addrs = append(addrs, addr)
+	}
+	sort.Strings(addrs)
+	for n, addr := range addrs {
+		addrs[n] = addr + ": " + e[addr].Error()
+	}
+	return "errors on " + strconv.Itoa(len(e)) + " server(s): " +
+		strings.Join(addrs, ", ")
+}
+
+// ForEachServer calls fn concurrently for every server the client is
+// connected to. Failures are returned as ServerErrors.
+func (c *Client) ForEachServer(fn func(srv ServerInfo) error) error {
+	servers, err := c.Servers()
+	if err != nil {
+		return err
+	}
+	return forEach(servers, serverID, fn)
+}
+
+// forEach calls fn concurrently for every server, and returns failures as
+// ServerErrors keyed by id.
+func forEach(servers []ServerInfo, id func(ServerInfo) string, fn func(srv ServerInfo) error) error {
+	var lk sync.Mutex
+	errs := make(ServerErrors)
+	var wg sync.WaitGroup
+	for _, srv := range servers {
+		wg.Add(1)
+		go func(srv ServerInfo) {
+			defer wg.Done()
+			if err := fn(srv); err != nil {
+				lk.Lock()
+				errs[id(srv)] = err
+				lk.Unlock()
+			}
+		}(srv)
+	}
+	wg.Wait()
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// execOnAll executes a command on every server, and returns the replies
+// keyed by server, like ServerErrors. Replies of the servers that did not
+// fail are returned along with ServerErrors.
+func (c *Client) execOnAll(urp bool, a ...interface{}) (map[string]interface{}, error) {
+	servers, err := c.Servers()
+	if err != nil {
+		return nil, err
+	}
+	return c.execOnServers(urp, servers, serverID, a...)
+}
+
+// execOnInstances is like execOnAll, but executes the command once per
+// redis instance, and returns the replies keyed by server address. It's
+// used by commands that are not bound to a database, e.g. BGSAVE, CONFIG
+// and SCRIPT, which fail or are repeated when sent once per database.
+func (c *Client) execOnInstances(urp bool, a ...interface{}) (map[string]interface{}, error) {
+	servers, err := c.instances()
+	if err != nil {
+		return nil, err
+	}
+	return c.execOnServers(urp, servers, serverAddr, a...)
+}
+
+func (c *Client) execOnServers(urp bool, servers []ServerInfo, id func(ServerInfo) string, a ...interface{}) (map[string]interface{}, error) {
+	var lk sync.Mutex
+	r := make(map[string]interface{})
+	err := forEach(servers, id, func(srv ServerInfo) error {
+		v, err := c.execWithAddr(urp, srv, a...)
+		if err != nil {
+			return err
+		}
+		lk.Lock()
+		r[id(srv)] = v
+		lk.Unlock()
+		return nil
+	})
+	return r, err
 }
 
 // execOnFirst executes a command on the first listed server.
@@ -330,12 +664,12 @@ func (c *Client) execWithAddr(urp bool, srv ServerInfo, a ...interface{}) (v int
 
 // execWithAddrTimeout executes a command in a specific redis server,
 // extending the connection timeout for the given command.
-func (c *Client) execWithAddrTimeout(urp bool, srv ServerInfo, timeout int, a ...interface{}) (v interface{}, err error) {
+func (c *Client) execWithAddrTimeout(urp bool, srv ServerInfo, timeout time.Duration, a ...interface{}) (v interface{}, err error) {
 	cn, err := c.getConn(srv)
 	if err != nil {
 		return
 	}
-	cn.extendDeadline(time.Duration(timeout) * time.Second)
+	cn.extendDeadline(timeout)
 	defer cn.condRelease(&err)
 	if urp {
 		return c.execute_urp(cn.rw, a...)
@@ -364,8 +698,16 @@ func (c *Client) execute(rw *bufio.ReadWriter, a ...interface{}) (v interface{},
 // It uses the current protocol and must be used by most commands, such as SET.
 // Redis protocol <http://redis.io/topics/protocol>
 func (c *Client) execute_urp(rw *bufio.ReadWriter, a ...interface{}) (v interface{}, err error) {
+	if err = c.send_urp(rw, a...); err != nil {
+		return
+	}
+	return c.parseResponse(rw.Reader)
+}
+
+// send_urp sends a command to redis using the unified request protocol,
+// without reading the response.
+func (c *Client) send_urp(rw *bufio.ReadWriter, a ...interface{}) (err error) {
 	//fmt.Printf("\nSending: %#v\n", a)
-	// unified request protocol
 	s := autoconv_args(a)
 	_, err = fmt.Fprintf(rw, "*%d\r\n", len(a))
 	if err != nil {
@@ -377,10 +719,7 @@ func (c *Client) execute_urp(rw *bufio.ReadWriter, a ...interface{}) (v interfac
 			return
 		}
 	}
-	if err = rw.Flush(); err != nil {
-		return
-	}
-	return c.parseResponse(rw.Reader)
+	return rw.Flush()
 }
 
 // parseResponse reads and parses a single response from redis.
@@ -405,7 +744,7 @@ func (c *Client) parseResponse(r *bufio.Reader) (v interface{}, err error) {
 	}
 	switch reply {
 	case '-': // Error reply
-		err = errors.New(string(line))
+		err = Error(line)
 		return
 	case '+': // Status reply
 		v = string(line)
@@ -424,7 +763,7 @@ func (c *Client) parseResponse(r *bufio.Reader) (v interface{}, err error) {
 			return
 		}
 		if valueLen == -1 {
-			v = "" // err = ErrCacheMiss
+			v = nil // converted to "" by iface2str
 			return
 		}
 		b := make([]byte, valueLen+2) // 2==crlf, TODO: fix this
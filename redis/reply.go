@@ -0,0 +1,282 @@
+// Copyright 2013-2014 go-redis authors.  All rights reserved.
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+package redis
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// Reply is a reply of the server, as returned by Eval and other commands
+// whose replies are not known in advance, with methods to convert it to
+// Go types.
+//
+// Example:
+//
+//	n, err := redis.NewReply(rc.Eval("return redis.call('INCR', KEYS[1])", 1, []string{"k"}, nil)).Int64()
+//
+// Nil replies are converted to ErrNil by the methods that return a single
+// value, except Bool, and to zero values by the ones that return many.
+type Reply struct {
+	val interface{}
+	err error
+}
+
+// NewReply returns a Reply with the result of a command, so it can wrap
+// calls directly: redis.NewReply(rc.Eval(...)).
+func NewReply(v interface{}, err error) *Reply {
+	return &Reply{val: v, err: err}
+}
+
+// Val returns the raw reply: a string, an int, nil or a []interface{} of
+// those.
+func (r *Reply) Val() interface{} {
+	return r.val
+}
+
+// Err returns the error of the command, if any.
+func (r *Reply) Err() error {
+	return r.err
+}
+
+// Int64 converts an integer reply, or a string holding an integer.
+func (r *Reply) Int64() (int64, error) {
+	if r.err != nil {
+		return 0, r.err
+	}
+	return reply2int64(r.val)
+}
+
+// Float64 converts a string holding a float, or an integer reply.
+func (r *Reply) Float64() (float64, error) {
+	if r.err != nil {
+		return 0, r.err
+	}
+	return reply2float64(r.val)
+}
+
+// Bool converts an integer reply to true if it's not zero. Nil replies,
+// which is how Lua's false is returned, are false.
+func (r *Reply) Bool() (bool, error) {
+	if r.err != nil {
+		return false, r.err
+	}
+	return reply2bool(r.val)
+}
+
+// Text converts a string reply, or an integer reply to its decimal form.
+func (r *Reply) Text() (string, error) {
+	if r.err != nil {
+		return "", r.err
+	}
+	return reply2str(r.val)
+}
+
+// Bytes is like Text, but returns a byte slice.
+func (r *Reply) Bytes() ([]byte, error) {
+	s, err := r.Text()
+	if err != nil {
+		return nil, err
+	}
+	return []byte(s), nil
+}
+
+// StringSlice converts an array reply. Nil items are empty strings.
+func (r *Reply) StringSlice() ([]string, error) {
+	items, err := r.items()
+	if err != nil {
+		return nil, err
+	}
+	s := make([]string, len(items))
+	for n, item := range items {
+		if item == nil {
+			continue
+		}
+		if s[n], err = reply2str(item); err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+// Int64Slice converts an array reply of integers. Nil items are zero.
+func (r *Reply) Int64Slice() ([]int64, error) {
+	items, err := r.items()
+	if err != nil {
+		return nil, err
+	}
+	s := make([]int64, len(items))
+	for n, item := range items {
+		if item == nil {
+			continue
+		}
+		if s[n], err = reply2int64(item); err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+// StringMap converts an array reply of names followed by their values,
+// such as the reply of HGETALL.
+func (r *Reply) StringMap() (map[string]string, error) {
+	s, err := r.StringSlice()
+	if err != nil {
+		return nil, err
+	}
+	if len(s)%2 != 0 {
+		return nil, ErrInvalidType
+	}
+	m := make(map[string]string, len(s)/2)
+	for n := 0; n < len(s); n += 2 {
+		m[s[n]] = s[n+1]
+	}
+	return m, nil
+}
+
+// Scan copies the items of an array reply to the values pointed at by
+// dest, in order, or a single reply to dest[0]. The supported types are
+// *string, *[]byte, *int, *int64, *float64, *bool and *interface{}.
+// Nil items set the zero value.
+func (r *Reply) Scan(dest ...interface{}) error {
+	if r.err != nil {
+		return r.err
+	}
+	items, ok := r.val.([]interface{})
+	if !ok {
+		items = []interface{}{r.val}
+	}
+	if len(items) != len(dest) {
+		return fmt.Errorf("scan: %d values into %d destinations", len(items), len(dest))
+	}
+	for n, item := range items {
+		if err := scanReply(item, dest[n]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// items returns the items of an array reply. Empty arrays, which are
+// parsed as nil, have no items.
+func (r *Reply) items() ([]interface{}, error) {
+	if r.err != nil {
+		return nil, r.err
+	}
+	if r.val == nil {
+		return nil, nil
+	}
+	items, ok := r.val.([]interface{})
+	if !ok {
+		return nil, ErrInvalidType
+	}
+	return items, nil
+}
+
+// scanReply copies a single reply to the value pointed at by dest.
+func scanReply(v interface{}, dest interface{}) (err error) {
+	if p, ok := dest.(*interface{}); ok {
+		*p = v
+		return nil
+	}
+	if v == nil {
+		switch p := dest.(type) {
+		case *string:
+			*p = ""
+		case *[]byte:
+			*p = nil
+		case *int:
+			*p = 0
+		case *int64:
+			*p = 0
+		case *float64:
+			*p = 0
+		case *bool:
+			*p = false
+		default:
+			return ErrInvalidType
+		}
+		return nil
+	}
+	switch p := dest.(type) {
+	case *string:
+		*p, err = reply2str(v)
+	case *[]byte:
+		var s string
+		if s, err = reply2str(v); err == nil {
+			*p = []byte(s)
+		}
+	case *int:
+		var i int64
+		if i, err = reply2int64(v); err == nil {
+			*p = int(i)
+		}
+	case *int64:
+		*p, err = reply2int64(v)
+	case *float64:
+		*p, err = reply2float64(v)
+	case *bool:
+		*p, err = reply2bool(v)
+	default:
+		err = ErrInvalidType
+	}
+	return
+}
+
+func reply2int64(v interface{}) (int64, error) {
+	switch v := v.(type) {
+	case int:
+		return int64(v), nil
+	case string:
+		return strconv.ParseInt(v, 10, 64)
+	case nil:
+		return 0, ErrNil
+	}
+	return 0, ErrInvalidType
+}
+
+func reply2float64(v interface{}) (float64, error) {
+	switch v := v.(type) {
+	case int:
+		return float64(v), nil
+	case string:
+		return strconv.ParseFloat(v, 64)
+	case nil:
+		return 0, ErrNil
+	}
+	return 0, ErrInvalidType
+}
+
+func reply2bool(v interface{}) (bool, error) {
+	switch v := v.(type) {
+	case int:
+		return v != 0, nil
+	case nil:
+		return false, nil
+	}
+	return false, ErrInvalidType
+}
+
+func reply2str(v interface{}) (string, error) {
+	switch v := v.(type) {
+	case string:
+		return v, nil
+	case int:
+		return strconv.Itoa(v), nil
+	case nil:
+		return "", ErrNil
+	}
+	return "", ErrInvalidType
+}
@@ -0,0 +1,129 @@
+// Copyright 2013-2014 go-redis authors.  All rights reserved.
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+package redis
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestReplyConversions(t *testing.T) {
+	if n, err := NewReply(42, nil).Int64(); err != nil || n != 42 {
+		t.Fatalf(errUnexpected, n)
+	}
+	if n, err := NewReply("-7", nil).Int64(); err != nil || n != -7 {
+		t.Fatalf(errUnexpected, n)
+	}
+	if f, err := NewReply("1.5", nil).Float64(); err != nil || f != 1.5 {
+		t.Fatalf(errUnexpected, f)
+	}
+	if ok, err := NewReply(1, nil).Bool(); err != nil || !ok {
+		t.Fatalf(errUnexpected, ok)
+	}
+	if ok, err := NewReply(nil, nil).Bool(); err != nil || ok {
+		t.Fatalf(errUnexpected, ok)
+	}
+	if s, err := NewReply(3, nil).Text(); err != nil || s != "3" {
+		t.Fatalf(errUnexpected, s)
+	}
+	if b, err := NewReply("abc", nil).Bytes(); err != nil || string(b) != "abc" {
+		t.Fatalf(errUnexpected, b)
+	}
+	if _, err := NewReply(nil, nil).Text(); err != ErrNil {
+		t.Fatalf(errUnexpected, err)
+	}
+	if _, err := NewReply([]interface{}{"a"}, nil).Int64(); err != ErrInvalidType {
+		t.Fatalf(errUnexpected, err)
+	}
+	e := errors.New("failed")
+	if _, err := NewReply(nil, e).StringSlice(); err != e {
+		t.Fatalf(errUnexpected, err)
+	}
+	a := []interface{}{"a", 1, nil}
+	if s, err := NewReply(a, nil).StringSlice(); err != nil ||
+		!reflect.DeepEqual(s, []string{"a", "1", ""}) {
+		t.Fatalf(errUnexpected, s)
+	}
+	if s, err := NewReply(nil, nil).StringSlice(); err != nil || len(s) != 0 {
+		t.Fatalf(errUnexpected, s)
+	}
+	if s, err := NewReply([]interface{}{1, "2", nil}, nil).Int64Slice(); err != nil ||
+		!reflect.DeepEqual(s, []int64{1, 2, 0}) {
+		t.Fatalf(errUnexpected, s)
+	}
+	if m, err := NewReply([]interface{}{"k", "v"}, nil).StringMap(); err != nil ||
+		!reflect.DeepEqual(m, map[string]string{"k": "v"}) {
+		t.Fatalf(errUnexpected, m)
+	}
+	if _, err := NewReply([]interface{}{"k"}, nil).StringMap(); err != ErrInvalidType {
+		t.Fatalf(errUnexpected, err)
+	}
+}
+
+func TestReplyScan(t *testing.T) {
+	var (
+		s   string
+		b   []byte
+		i   int
+		i64 int64
+		f   float64
+		ok  bool
+		v   interface{}
+	)
+	a := []interface{}{"a", "b", 1, 2, "0.5", 1, []interface{}{"x"}}
+	if err := NewReply(a, nil).Scan(&s, &b, &i, &i64, &f, &ok, &v); err != nil {
+		t.Fatal(err)
+	}
+	if s != "a" || string(b) != "b" || i != 1 || i64 != 2 || f != 0.5 || !ok ||
+		!reflect.DeepEqual(v, []interface{}{"x"}) {
+		t.Fatalf(errUnexpected, a)
+	}
+	if err := NewReply([]interface{}{nil, nil}, nil).Scan(&s, &i); err != nil {
+		t.Fatal(err)
+	} else if s != "" || i != 0 {
+		t.Fatalf(errUnexpected, s)
+	}
+	if err := NewReply("single", nil).Scan(&s); err != nil || s != "single" {
+		t.Fatalf(errUnexpected, s)
+	}
+	if err := NewReply(a, nil).Scan(&s); err == nil {
+		t.Fatal("Scan ignored extra values")
+	}
+	var u uint
+	if err := NewReply(1, nil).Scan(&u); err != ErrInvalidType {
+		t.Fatalf(errUnexpected, err)
+	}
+}
+
+func TestReplyEval(t *testing.T) {
+	k := randomString(16)
+	defer rc.Del(k)
+	rc.Set(k, "10", SetArgs{})
+	n, err := NewReply(rc.Eval("return redis.call('INCR', KEYS[1])", 1, []string{k}, nil)).Int64()
+	if err != nil {
+		t.Fatal(err)
+	} else if n != 11 {
+		t.Fatalf(errUnexpected, n)
+	}
+	var name string
+	var count int
+	r := NewReply(rc.Eval("return {ARGV[1], redis.call('GET', KEYS[1])}", 1, []string{k}, []string{"k"}))
+	if err := r.Scan(&name, &count); err != nil {
+		t.Fatal(err)
+	} else if name != "k" || count != 11 {
+		t.Fatalf(errUnexpected, r.Val())
+	}
+}
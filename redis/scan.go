@@ -0,0 +1,229 @@
+// Copyright 2013-2014 go-redis authors.  All rights reserved.
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+package redis
+
+import (
+	"strconv"
+	"sync"
+)
+
+// ScanOptions are the optional arguments of SCAN.
+type ScanOptions struct {
+	// Match only returns keys matching the glob-style pattern.
+	Match string
+
+	// Count is a hint of how many keys redis should return per call.
+	// If zero, the redis default is used.
+	Count int
+
+	// Type only returns keys of the given type, e.g. "hash".
+	// It requires redis 6.0 or newer.
+	Type string
+}
+
+// args returns the options in the form of command arguments.
+func (o ScanOptions) args() []interface{} {
+	var a []interface{}
+	if o.Match != "" {
+		a = append(a, "MATCH", o.Match)
+	}
+	if o.Count > 0 {
+		a = append(a, "COUNT", o.Count)
+	}
+	if o.Type != "" {
+		a = append(a, "TYPE", o.Type)
+	}
+	return a
+}
+
+// parseScanReply parses the reply of the SCAN family of commands, which
+// is the next cursor followed by a list of items.
+func parseScanReply(v interface{}) (cursor uint64, items []interface{}, err error) {
+	r, ok := v.([]interface{})
+	if !ok || len(r) != 2 {
+		err = ErrServerError
+		return
+	}
+	s, err := iface2str(r[0])
+	if err != nil {
+		return
+	}
+	cursor, err = strconv.ParseUint(s, 10, 64)
+	if err != nil {
+		return
+	}
+	switch r[1].(type) {
+	case []interface{}:
+		items = r[1].([]interface{})
+	case nil:
+	default:
+		err = ErrServerError
+	}
+	return
+}
+
+// scan executes SCAN on a specific server.
+func (c *Client) scan(srv ServerInfo, cursor uint64, opts ScanOptions) (uint64, []string, error) {
+	a := append([]interface{}{"SCAN", cursor}, opts.args()...)
+	v, err := c.execWithAddr(true, srv, a...)
+	if err != nil {
+		return 0, nil, err
+	}
+	cursor, items, err := parseScanReply(v)
+	if err != nil {
+		return 0, nil, err
+	}
+	return cursor, iface2vstr(items), nil
+}
+
+// ScanIterator iterates over the keys of every server with SCAN.
+// It's not safe for concurrent use.
+//
+// Example:
+//
+//	it := rc.Scan(redis.ScanOptions{Match: "user:*"})
+//	for it.Next() {
+//		fmt.Println(it.Val())
+//	}
+//	if err := it.Err(); err != nil {
+//		...
+//	}
+//
+// SCAN guarantees that keys present during the whole iteration are
+// returned, but they may be returned more than once.
+type ScanIterator struct {
+	c       *Client
+	opts    ScanOptions
+	servers []ServerInfo
+	srv     int
+	cursor  uint64
+	more    bool
+	keys    []string
+	val     string
+	err     error
+}
+
+// http://redis.io/commands/scan
+// Scan returns an iterator over the keys of all servers, one server after
+// the other. Servers are only contacted when Next is called.
+func (c *Client) Scan(opts ScanOptions) *ScanIterator {
+	return &ScanIterator{c: c, opts: opts}
+}
+
+// Next advances the iterator to the next key, calling SCAN as needed.
+// It returns false when there are no more keys or an error occurs.
+func (it *ScanIterator) Next() bool {
+	if it.err != nil {
+		return false
+	}
+	if it.servers == nil {
+		if it.servers, it.err = it.c.Servers(); it.err != nil {
+			return false
+		}
+		it.more = true
+	}
+	for len(it.keys) == 0 {
+		if !it.more {
+			if it.srv+1 >= len(it.servers) {
+				return false
+			}
+			it.srv++
+			it.cursor = 0
+			it.more = true
+		}
+		it.cursor, it.keys, it.err = it.c.scan(
+			it.servers[it.srv], it.cursor, it.opts)
+		if it.err != nil {
+			return false
+		}
+		it.more = it.cursor != 0
+	}
+	it.val = it.keys[0]
+	it.keys = it.keys[1:]
+	return true
+}
+
+// Val returns the current key.
+func (it *ScanIterator) Val() string {
+	return it.val
+}
+
+// Err returns the error that stopped the iteration, if any.
+func (it *ScanIterator) Err() error {
+	return it.err
+}
+
+// ScanConcurrent scans up to workers servers at the same time, calling fn
+// for every key. It's meant for walking the keyspace of large sharded
+// connections quickly.
+//
+// fn is called concurrently by multiple goroutines. The scan stops when
+// fn returns an error, which is then returned by ScanConcurrent.
+// A workers value of zero or less scans all servers at the same time.
+func (c *Client) ScanConcurrent(opts ScanOptions, workers int, fn func(key string) error) error {
+	servers, err := c.Servers()
+	if err != nil {
+		return err
+	}
+	if workers <= 0 || workers > len(servers) {
+		workers = len(servers)
+	}
+	var (
+		wg   sync.WaitGroup
+		lk   sync.Mutex
+		stop bool
+	)
+	sem := make(chan bool, workers)
+	stopped := func() bool {
+		lk.Lock()
+		defer lk.Unlock()
+		return stop
+	}
+	fail := func(e error) {
+		lk.Lock()
+		defer lk.Unlock()
+		if !stop {
+			stop = true
+			err = e
+		}
+	}
+	for _, srv := range servers {
+		wg.Add(1)
+		go func(srv ServerInfo) {
+			defer wg.Done()
+			sem <- true
+			defer func() { <-sem }()
+			var cursor uint64
+			for !stopped() {
+				next, keys, e := c.scan(srv, cursor, opts)
+				if e != nil {
+					fail(e)
+					return
+				}
+				for _, k := range keys {
+					if e = fn(k); e != nil {
+						fail(e)
+						return
+					}
+				}
+				if cursor = next; cursor == 0 {
+					return
+				}
+			}
+		}(srv)
+	}
+	wg.Wait()
+	return err
+}
@@ -0,0 +1,107 @@
+// Copyright 2013-2014 go-redis authors.  All rights reserved.
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+package redis
+
+import (
+	"errors"
+	"strconv"
+	"sync"
+	"testing"
+)
+
+// setScanKeys creates n keys with a random prefix, and returns the prefix.
+func setScanKeys(t *testing.T, n int) (prefix string, keys []string) {
+	prefix = randomString(8) + ":"
+	items := make(map[string]string)
+	for i := 0; i < n; i++ {
+		k := prefix + strconv.Itoa(i)
+		items[k] = "v"
+		keys = append(keys, k)
+	}
+	if err := rc.MSet(items); err != nil {
+		t.Fatal(err)
+	}
+	return prefix, keys
+}
+
+func TestParseScanReply(t *testing.T) {
+	cursor, items, err := parseScanReply([]interface{}{
+		"42", []interface{}{"a", "b"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	} else if cursor != 42 || len(items) != 2 {
+		t.Fatalf(errUnexpected, items)
+	}
+	if _, _, err = parseScanReply("42"); err != ErrServerError {
+		t.Fatalf(errUnexpected, err)
+	}
+}
+
+// TestScan scans 100 keys, 10 at a time.
+func TestScan(t *testing.T) {
+	prefix, keys := setScanKeys(t, 100)
+	defer rc.Del(keys...)
+	seen := make(map[string]bool)
+	it := rc.Scan(ScanOptions{Match: prefix + "*", Count: 10})
+	for it.Next() {
+		seen[it.Val()] = true
+	}
+	if err := it.Err(); err != nil {
+		t.Fatal(err)
+	} else if len(seen) != len(keys) {
+		t.Fatalf(errUnexpected, len(seen))
+	}
+}
+
+// TestScanType checks that keys of other types are skipped.
+func TestScanType(t *testing.T) {
+	prefix, keys := setScanKeys(t, 10)
+	defer rc.Del(keys...)
+	it := rc.Scan(ScanOptions{Match: prefix + "*", Type: "list"})
+	for it.Next() {
+		t.Fatalf(errUnexpected, it.Val())
+	}
+	if err := it.Err(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestScanConcurrent(t *testing.T) {
+	prefix, keys := setScanKeys(t, 100)
+	defer rc.Del(keys...)
+	var lk sync.Mutex
+	n := 0
+	err := rc.ScanConcurrent(ScanOptions{Match: prefix + "*"}, 4,
+		func(key string) error {
+			lk.Lock()
+			n++
+			lk.Unlock()
+			return nil
+		})
+	if err != nil {
+		t.Fatal(err)
+	} else if n < len(keys) {
+		t.Fatalf(errUnexpected, n)
+	}
+	stop := errors.New("stop")
+	err = rc.ScanConcurrent(ScanOptions{Match: prefix + "*"}, 4,
+		func(key string) error {
+			return stop
+		})
+	if err != stop {
+		t.Fatalf(errUnexpected, err)
+	}
+}
@@ -0,0 +1,77 @@
+// Copyright 2013-2014 go-redis authors.  All rights reserved.
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+package redis
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"strings"
+)
+
+// Script is a Lua script that runs with EVALSHA, so its body is only sent
+// to servers that don't have it cached yet.
+//
+// Example:
+//
+//	var incrMax = redis.NewScript(`
+//		local v = redis.call('INCR', KEYS[1])
+//		if v > tonumber(ARGV[1]) then redis.call('SET', KEYS[1], ARGV[1]) end
+//		return v`)
+//
+//	v, err := incrMax.Run(rc, []string{"counter"}, "100")
+//
+// Scripts are routed like Eval, by their keys.
+type Script struct {
+	src  string
+	hash string
+}
+
+// NewScript returns a Script with the given source.
+func NewScript(src string) *Script {
+	h := sha1.Sum([]byte(src))
+	return &Script{src: src, hash: hex.EncodeToString(h[:])}
+}
+
+// Hash returns the SHA1 digest of the script, as used by EVALSHA.
+func (s *Script) Hash() string {
+	return s.hash
+}
+
+// Load loads the script on every server of c. It's not required before
+// Run, but saves sending the script on its first run on each server.
+func (s *Script) Load(c *Client) error {
+	_, err := c.ScriptLoad(s.src)
+	return err
+}
+
+// Run runs the script with EVALSHA, and with EVAL if the server does not
+// have it cached, which caches it.
+func (s *Script) Run(c *Client, keys []string, args ...string) (interface{}, error) {
+	return s.run(c, "EVALSHA", "EVAL", keys, args)
+}
+
+// RunRO is the read-only version of Run, which can be served by replicas.
+// It requires redis 7.0 or newer.
+func (s *Script) RunRO(c *Client, keys []string, args ...string) (interface{}, error) {
+	return s.run(c, "EVALSHA_RO", "EVAL_RO", keys, args)
+}
+
+func (s *Script) run(c *Client, evalsha, eval string, keys, args []string) (interface{}, error) {
+	v, err := c.eval(evalsha, s.hash, len(keys), keys, args)
+	if e, ok := err.(Error); ok && strings.HasPrefix(string(e), "NOSCRIPT") {
+		return c.eval(eval, s.src, len(keys), keys, args)
+	}
+	return v, err
+}
@@ -0,0 +1,112 @@
+// Copyright 2013-2014 go-redis authors.  All rights reserved.
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+package redis
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestScriptHash(t *testing.T) {
+	// echo -n "return 1" | sha1sum
+	s := NewScript("return 1")
+	if h := s.Hash(); h != "e0e1f9fabfc9d4800c877a703b823ac0578ff8db" {
+		t.Fatalf(errUnexpected, h)
+	}
+}
+
+func TestScript(t *testing.T) {
+	k := randomString(16)
+	defer rc.Del(k)
+	rc.Set(k, "hello", SetArgs{})
+	s := NewScript("return {redis.call('GET', KEYS[1]), ARGV[1]}")
+	if err := rc.ScriptFlush(); err != nil {
+		t.Fatal(err)
+	}
+	if ok, err := rc.ScriptExists(s.Hash()); err != nil {
+		t.Fatal(err)
+	} else if !reflect.DeepEqual(ok, []bool{false}) {
+		t.Fatalf(errUnexpected, ok)
+	}
+	for n := 0; n < 2; n++ {
+		// Falls back to EVAL first, then EVALSHA succeeds.
+		v, err := s.Run(rc, []string{k}, "world")
+		if err != nil {
+			t.Fatal(err)
+		} else if !reflect.DeepEqual(v, []interface{}{"hello", "world"}) {
+			t.Fatalf(errUnexpected, v)
+		}
+	}
+	if ok, err := rc.ScriptExists(s.Hash(), "nosuchscript"); err != nil {
+		t.Fatal(err)
+	} else if !reflect.DeepEqual(ok, []bool{true, false}) {
+		t.Fatalf(errUnexpected, ok)
+	}
+	if v, err := s.RunRO(rc, []string{k}, "world"); err != nil {
+		t.Fatal(err)
+	} else if !reflect.DeepEqual(v, []interface{}{"hello", "world"}) {
+		t.Fatalf(errUnexpected, v)
+	}
+	ro := NewScript("return redis.call('SET', KEYS[1], ARGV[1])")
+	if _, err := ro.RunRO(rc, []string{k}, "world"); err == nil {
+		t.Fatal("RunRO ran a write command")
+	}
+}
+
+func TestScriptKill(t *testing.T) {
+	err := rc.ScriptKill()
+	if e, ok := err.(Error); !ok || !strings.HasPrefix(string(e), "NOTBUSY") {
+		t.Fatalf(errUnexpected, err)
+	}
+	notBusy := Error("NOTBUSY No scripts in execution right now.")
+	busy := map[string]interface{}{"a": "OK"}
+	if err = ignoreNotBusy(busy, ServerErrors{"b": notBusy}); err != nil {
+		t.Fatalf(errUnexpected, err)
+	}
+	if err = ignoreNotBusy(nil, ServerErrors{"a": notBusy, "b": notBusy}); err != notBusy {
+		t.Fatalf(errUnexpected, err)
+	}
+	err = ignoreNotBusy(busy, ServerErrors{"b": notBusy, "c": ErrTimedOut})
+	if e, ok := err.(ServerErrors); !ok || len(e) != 1 || e["c"] != ErrTimedOut {
+		t.Fatalf(errUnexpected, err)
+	}
+}
+
+func TestScriptSharded(t *testing.T) {
+	c := New("127.0.0.1:6379", "127.0.0.1:6379 db=1")
+	keys := keysOnDistinctServers(t, c, "script", 2)
+	defer c.Del(keys[0], keys[1])
+	c.Set(keys[0], "db0", SetArgs{})
+	c.Set(keys[1], "db1", SetArgs{})
+	s := NewScript("return redis.call('GET', KEYS[1])")
+	if err := s.Load(c); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"db0", "db1"}
+	for i, k := range keys {
+		if v, err := s.Run(c, []string{k}); err != nil {
+			t.Fatal(err)
+		} else if v != want[i] {
+			t.Fatalf(errUnexpected, v)
+		}
+	}
+	if _, err := s.Run(c, []string{keys[0], keys[1]}); err != ErrCrossShard {
+		t.Fatalf(errUnexpected, err)
+	}
+	if _, err := c.Eval("return 1", 2, []string{keys[0], keys[1]}, nil); err != ErrCrossShard {
+		t.Fatalf(errUnexpected, err)
+	}
+}
@@ -21,9 +21,13 @@ import (
 	"errors"
 	"fmt"
 	"hash/crc32"
+	"math/rand"
 	"net"
+	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
 )
 
 // ServerSelector is the interface that selects a redis server as a function
@@ -40,11 +44,44 @@ type ServerSelector interface {
 	Sharding() bool
 }
 
+// ReadSelector is an optional interface implemented by ServerSelectors
+// that can route read-only commands (GET, HGETALL, LRANGE, etc) away from
+// the server that owns the key, e.g. to one of its replicas.
+//
+// All ReadSelector implementations must be threadsafe.
+type ReadSelector interface {
+	// PickReadServer returns the server that should serve a read-only
+	// command for the given key.
+	PickReadServer(key string) (ServerInfo, error)
+}
+
+// ServerIterator is an optional interface implemented by ServerSelectors
+// that can enumerate all of their servers. It's required by commands that
+// run on every server, such as FlushDB; on selectors that don't implement
+// it, these commands only run on the first server.
+//
+// All ServerIterator implementations must be threadsafe.
+type ServerIterator interface {
+	// Each calls fn for every server that keys are shared onto,
+	// stopping at the first error.
+	Each(fn func(ServerInfo) error) error
+}
+
+// latencyObserver is implemented by selectors that want to know how long
+// read-only commands take on each server. See ReplicaList.
+type latencyObserver interface {
+	ObserveLatency(srv ServerInfo, d time.Duration)
+}
+
 // ServerInfo stores parsed the server information, ip:port, dbid and passwd.
 type ServerInfo struct {
 	Addr   net.Addr
 	DB     string
 	Passwd string
+
+	// ReadOnly makes the client send READONLY on every new connection
+	// to the server, as required by redis cluster replicas.
+	ReadOnly bool
 }
 
 // ServerList is a simple ServerSelector. Its zero value is usable.
@@ -65,6 +102,12 @@ func parseOptions(srv *ServerInfo, opts []string) error {
 			srv.DB = items[1]
 		case "passwd":
 			srv.Passwd = items[1]
+		case "readonly":
+			ro, err := strconv.ParseBool(items[1])
+			if err != nil {
+				return errors.New("Invalid option " + opt)
+			}
+			srv.ReadOnly = ro
 		default:
 			return errors.New("Unknown option " + opt)
 		}
@@ -72,6 +115,32 @@ func parseOptions(srv *ServerInfo, opts []string) error {
 	return nil
 }
 
+// parseServer parses a server string in the form of "addr [opts]",
+// where addr is ip:port or /unix/path.
+func parseServer(server string) (srv ServerInfo, err error) {
+	// addr db=N passwd=foobar
+	items := strings.Split(server, " ")
+	if strings.Contains(items[0], "/") {
+		srv.Addr, err = net.ResolveUnixAddr("unix", items[0])
+	} else {
+		srv.Addr, err = net.ResolveTCPAddr("tcp", items[0])
+	}
+	if err != nil {
+		return srv, fmt.Errorf(
+			"Invalid redis server '%s': %s",
+			server, err)
+	}
+	// parse connection options
+	if len(items) > 1 {
+		if err = parseOptions(&srv, items[1:]); err != nil {
+			return srv, fmt.Errorf(
+				"Invalid redis server '%s': %s",
+				server, err)
+		}
+	}
+	return srv, nil
+}
+
 // SetServers changes a ServerList's set of servers at runtime and is
 // threadsafe.
 //
@@ -82,35 +151,17 @@ func parseOptions(srv *ServerInfo, opts []string) error {
 // resolve. No attempt is made to connect to the server. If any error
 // is returned, no changes are made to the ServerList.
 func (ss *ServerList) SetServers(servers ...string) error {
-	var err error
-	var fs, addr net.Addr
+	var fs net.Addr
 	nsrv := make([]ServerInfo, len(servers))
 	for i, server := range servers {
-		// addr db=N passwd=foobar
-		items := strings.Split(server, " ")
-		if strings.Contains(items[0], "/") {
-			addr, err = net.ResolveUnixAddr("unix", items[0])
-		} else {
-			addr, err = net.ResolveTCPAddr("tcp", items[0])
-		}
+		srv, err := parseServer(server)
 		if err != nil {
-			return fmt.Errorf(
-				"Invalid redis server '%s': %s",
-				server, err)
-		} else {
-			nsrv[i].Addr = addr
-		}
-		// parse connection options
-		if len(items) > 1 {
-			if err := parseOptions(&nsrv[i], items[1:]); err != nil {
-				return fmt.Errorf(
-					"Invalid redis server '%s': %s",
-					server, err)
-			}
+			return err
 		}
+		nsrv[i] = srv
 		if i == 0 {
-			fs = addr
-		} else if fs != addr && !ss.sharding {
+			fs = srv.Addr
+		} else if fs != srv.Addr && !ss.sharding {
 			ss.sharding = true
 		}
 	}
@@ -124,6 +175,19 @@ func (ss *ServerList) Sharding() bool {
 	return ss.sharding
 }
 
+// Each calls fn for every server of the ServerList.
+func (ss *ServerList) Each(fn func(ServerInfo) error) error {
+	ss.lk.RLock()
+	servers := ss.servers
+	ss.lk.RUnlock()
+	for _, srv := range servers {
+		if err := fn(srv); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func (ss *ServerList) PickServer(key string) (srv ServerInfo, err error) {
 	ss.lk.RLock()
 	defer ss.lk.RUnlock()
@@ -138,3 +202,176 @@ func (ss *ServerList) PickServer(key string) (srv ServerInfo, err error) {
 	}
 	return
 }
+
+// ReadPolicy determines which server of a shard serves read-only commands.
+type ReadPolicy int
+
+const (
+	// ReadPrimary sends all commands to the primary server.
+	ReadPrimary ReadPolicy = iota
+
+	// ReadRandom picks a random replica for each read-only command.
+	ReadRandom
+
+	// ReadRoundRobin cycles through the replicas.
+	ReadRoundRobin
+
+	// ReadLowestLatency picks the replica with the lowest average
+	// latency observed by the client so far.
+	ReadLowestLatency
+)
+
+// ReplicaList is a ServerSelector for shards made of one primary server
+// and zero or more replicas. Keys are distributed across shards like
+// ServerList does. Writes always go to the primary server of the key's
+// shard, and read-only commands are routed to its replicas according to
+// Policy. Shards without replicas serve everything from the primary.
+//
+// Replication is asynchronous, so reads from replicas may return stale
+// data. Its zero value is usable.
+type ReplicaList struct {
+	// Policy selects the replica that serves read-only commands.
+	// It must be set before the ReplicaList is used by a Client.
+	Policy ReadPolicy
+
+	lk      sync.RWMutex
+	shards  []replicaShard
+	latency map[string]time.Duration
+	next    uint32
+}
+
+type replicaShard struct {
+	primary  ServerInfo
+	replicas []ServerInfo
+}
+
+// SetShards changes a ReplicaList's set of shards at runtime and is
+// threadsafe.
+//
+// Each shard is a list of servers in the same format of ServerList, where
+// the first server is the primary and the others are its replicas.
+// Replicas of a redis cluster require the readonly=true option.
+// Example:
+//
+//	rl.SetShards(
+//		[]string{"10.0.0.1:6379", "10.0.0.11:6379", "10.0.0.12:6379"},
+//		[]string{"10.0.0.2:6379", "10.0.0.21:6379"},
+//	)
+//
+// If any error is returned, no changes are made to the ReplicaList.
+func (rl *ReplicaList) SetShards(shards ...[]string) error {
+	nshards := make([]replicaShard, len(shards))
+	for i, servers := range shards {
+		if len(servers) == 0 {
+			return fmt.Errorf("Invalid redis shard %d: no servers", i)
+		}
+		for n, server := range servers {
+			srv, err := parseServer(server)
+			if err != nil {
+				return err
+			}
+			if n == 0 {
+				nshards[i].primary = srv
+			} else {
+				nshards[i].replicas = append(nshards[i].replicas, srv)
+			}
+		}
+	}
+	rl.lk.Lock()
+	defer rl.lk.Unlock()
+	rl.shards = nshards
+	return nil
+}
+
+func (rl *ReplicaList) Sharding() bool {
+	rl.lk.RLock()
+	defer rl.lk.RUnlock()
+	return len(rl.shards) > 1
+}
+
+// Each calls fn for the primary server of every shard.
+func (rl *ReplicaList) Each(fn func(ServerInfo) error) error {
+	rl.lk.RLock()
+	shards := rl.shards
+	rl.lk.RUnlock()
+	for _, s := range shards {
+		if err := fn(s.primary); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// shard returns the shard of the given key. It must be called with rl.lk
+// held.
+func (rl *ReplicaList) shard(key string) (*replicaShard, error) {
+	if len(rl.shards) == 0 {
+		return nil, ErrNoServers
+	}
+	if key == "" {
+		return &rl.shards[0], nil
+	}
+	return &rl.shards[crc32.ChecksumIEEE([]byte(key))%uint32(len(rl.shards))], nil
+}
+
+// PickServer returns the primary server of the key's shard.
+func (rl *ReplicaList) PickServer(key string) (srv ServerInfo, err error) {
+	rl.lk.RLock()
+	defer rl.lk.RUnlock()
+	s, err := rl.shard(key)
+	if err != nil {
+		return
+	}
+	return s.primary, nil
+}
+
+// PickReadServer returns one of the replicas of the key's shard,
+// according to Policy.
+func (rl *ReplicaList) PickReadServer(key string) (srv ServerInfo, err error) {
+	rl.lk.RLock()
+	defer rl.lk.RUnlock()
+	s, err := rl.shard(key)
+	if err != nil {
+		return
+	}
+	if len(s.replicas) == 0 {
+		return s.primary, nil
+	}
+	switch rl.Policy {
+	case ReadRandom:
+		srv = s.replicas[rand.Intn(len(s.replicas))]
+	case ReadRoundRobin:
+		n := atomic.AddUint32(&rl.next, 1)
+		srv = s.replicas[n%uint32(len(s.replicas))]
+	case ReadLowestLatency:
+		// Servers not measured yet have zero latency, which makes
+		// sure every replica is tried at least once.
+		srv = s.replicas[0]
+		best := rl.latency[srv.Addr.String()]
+		for _, r := range s.replicas[1:] {
+			if d := rl.latency[r.Addr.String()]; d < best {
+				srv, best = r, d
+			}
+		}
+	default:
+		srv = s.primary
+	}
+	return
+}
+
+// ObserveLatency records how long a read-only command took on srv.
+// It's called by the Client and used by the ReadLowestLatency policy,
+// which compares the moving average of each replica.
+func (rl *ReplicaList) ObserveLatency(srv ServerInfo, d time.Duration) {
+	k := srv.Addr.String()
+	rl.lk.Lock()
+	defer rl.lk.Unlock()
+	if rl.latency == nil {
+		rl.latency = make(map[string]time.Duration)
+	}
+	if avg, ok := rl.latency[k]; ok {
+		rl.latency[k] = (avg*4 + d) / 5
+	} else {
+		rl.latency[k] = d
+	}
+}
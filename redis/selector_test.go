@@ -0,0 +1,145 @@
+// Copyright 2013-2014 go-redis authors.  All rights reserved.
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+package redis
+
+import (
+	"testing"
+	"time"
+)
+
+func newReplicaList(t *testing.T, policy ReadPolicy) *ReplicaList {
+	rl := &ReplicaList{Policy: policy}
+	err := rl.SetShards(
+		[]string{"127.0.0.1:6379", "127.0.0.1:6380", "127.0.0.1:6381 readonly=true"},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return rl
+}
+
+func TestReplicaListPickServer(t *testing.T) {
+	rl := newReplicaList(t, ReadRoundRobin)
+	for _, k := range []string{"", "foo", "bar"} {
+		srv, err := rl.PickServer(k)
+		if err != nil {
+			t.Fatal(err)
+		} else if srv.Addr.String() != "127.0.0.1:6379" {
+			t.Fatalf(errUnexpected, srv.Addr.String())
+		}
+	}
+}
+
+func TestReplicaListRoundRobin(t *testing.T) {
+	rl := newReplicaList(t, ReadRoundRobin)
+	seen := make(map[string]int)
+	for n := 0; n < 4; n++ {
+		srv, err := rl.PickReadServer("foo")
+		if err != nil {
+			t.Fatal(err)
+		}
+		seen[srv.Addr.String()]++
+	}
+	if seen["127.0.0.1:6380"] != 2 || seen["127.0.0.1:6381"] != 2 {
+		t.Fatalf(errUnexpected, seen)
+	}
+}
+
+func TestReplicaListReadOnlyOption(t *testing.T) {
+	rl := newReplicaList(t, ReadRoundRobin)
+	for n := 0; n < 2; n++ {
+		srv, _ := rl.PickReadServer("foo")
+		if srv.ReadOnly != (srv.Addr.String() == "127.0.0.1:6381") {
+			t.Fatalf(errUnexpected, srv)
+		}
+	}
+	if err := rl.SetShards([]string{"127.0.0.1:6379 readonly=maybe"}); err == nil {
+		t.Fatal("Invalid readonly option was accepted")
+	}
+}
+
+func TestReplicaListLowestLatency(t *testing.T) {
+	rl := newReplicaList(t, ReadLowestLatency)
+	slow, _ := parseServer("127.0.0.1:6380")
+	fast, _ := parseServer("127.0.0.1:6381")
+	rl.ObserveLatency(slow, 10*time.Millisecond)
+	rl.ObserveLatency(fast, time.Millisecond)
+	if srv, err := rl.PickReadServer("foo"); err != nil {
+		t.Fatal(err)
+	} else if srv.Addr.String() != "127.0.0.1:6381" {
+		t.Fatalf(errUnexpected, srv.Addr.String())
+	}
+}
+
+func TestReplicaListReadPrimary(t *testing.T) {
+	rl := newReplicaList(t, ReadPrimary)
+	if srv, err := rl.PickReadServer("foo"); err != nil {
+		t.Fatal(err)
+	} else if srv.Addr.String() != "127.0.0.1:6379" {
+		t.Fatalf(errUnexpected, srv.Addr.String())
+	}
+}
+
+// TestReplicaListMultiKeyRead checks that the keys of a shard are sent to
+// the same replica, rather than a replica per key.
+func TestReplicaListMultiKeyRead(t *testing.T) {
+	c := NewFromSelector(newReplicaList(t, ReadRoundRobin))
+	for n := 0; n < 4; n++ {
+		srv, err := c.pickServerForKeys("SINTERCARD", []string{"a", "b", "c"})
+		if err != nil {
+			t.Fatal(err)
+		} else if srv.Addr.String() == "127.0.0.1:6379" {
+			t.Fatalf(errUnexpected, srv)
+		}
+	}
+	rl := &ReplicaList{Policy: ReadRoundRobin}
+	if err := rl.SetShards([]string{"127.0.0.1:6379", "127.0.0.1:6379 db=0"}); err != nil {
+		t.Fatal(err)
+	}
+	c = NewFromSelector(rl)
+	k1, k2 := randomString(16), randomString(16)
+	defer c.Del(k1, k2)
+	c.SAdd(k1, "a", "b")
+	c.SAdd(k2, "b", "c")
+	if n, err := c.SInterCard(0, k1, k2); err != nil {
+		t.Fatal(err)
+	} else if n != 1 {
+		t.Fatalf(errUnexpected, n)
+	}
+	c.Del(k1, k2)
+	c.PFAdd(k1, "a", "b")
+	c.PFAdd(k2, "b", "c")
+	if n, err := c.PFCount(k1, k2); err != nil {
+		t.Fatal(err)
+	} else if n != 3 {
+		t.Fatalf(errUnexpected, n)
+	}
+}
+
+func TestReplicaListNoServers(t *testing.T) {
+	rl := new(ReplicaList)
+	if _, err := rl.PickReadServer("foo"); err != ErrNoServers {
+		t.Fatalf(errUnexpected, err)
+	}
+}
+
+func TestIsReadOnly(t *testing.T) {
+	if !isReadOnly("get") || !isReadOnly("HGETALL") {
+		t.Fatal("GET and HGETALL must be read-only")
+	}
+	if isReadOnly("SET") || isReadOnly("BRPOPLPUSH") {
+		t.Fatal("SET and BRPOPLPUSH must not be read-only")
+	}
+}
@@ -0,0 +1,547 @@
+// Copyright 2013-2014 go-redis authors.  All rights reserved.
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+package redis
+
+import (
+	"errors"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// errStreamArgs is returned by XRead when streams are not keys followed by
+// the same number of IDs.
+var errStreamArgs = errors.New("streams must be keys followed by their ids")
+
+// StreamEntry is an entry of a stream. Fields is nil for entries deleted
+// while pending in a consumer group.
+type StreamEntry struct {
+	ID     string
+	Fields map[string]string
+}
+
+// XStream is a stream and some of its entries, as returned by XRead.
+type XStream struct {
+	Stream  string
+	Entries []StreamEntry
+}
+
+// XTrimOptions are the trimming arguments of XADD and XTRIM.
+type XTrimOptions struct {
+	// MaxLen evicts the oldest entries of streams longer than MaxLen.
+	// It's only used if MinID is empty.
+	MaxLen int
+
+	// MinID evicts the entries with IDs lower than MinID.
+	MinID string
+
+	// Approx allows redis to trim less entries than requested, which
+	// is more efficient, e.g. MAXLEN ~ 1000.
+	Approx bool
+
+	// Limit is the maximum number of entries evicted when Approx is set.
+	// If zero, the redis default is used.
+	Limit int
+}
+
+// args returns the options in the form of command arguments.
+func (o XTrimOptions) args() []interface{} {
+	var a []interface{}
+	if o.MinID != "" {
+		a = append(a, "MINID")
+	} else {
+		a = append(a, "MAXLEN")
+	}
+	if o.Approx {
+		a = append(a, "~")
+	} else {
+		a = append(a, "=")
+	}
+	if o.MinID != "" {
+		a = append(a, o.MinID)
+	} else {
+		a = append(a, o.MaxLen)
+	}
+	if o.Approx && o.Limit > 0 {
+		a = append(a, "LIMIT", o.Limit)
+	}
+	return a
+}
+
+// XPending is the summary of the pending entries of a consumer group.
+type XPending struct {
+	Count     int            // number of pending entries
+	Lower     string         // lowest pending ID
+	Higher    string         // highest pending ID
+	Consumers map[string]int // pending entries per consumer
+}
+
+// XPendingEntry is a pending entry of a consumer group.
+type XPendingEntry struct {
+	ID         string
+	Consumer   string        // consumer that owns the entry
+	Idle       time.Duration // time since the entry was last delivered
+	Deliveries int           // number of times the entry was delivered
+}
+
+// XPendingOptions select the entries returned by XPendingRange.
+type XPendingOptions struct {
+	// Start and End are the range of IDs. If empty, "-" and "+" are
+	// used, which are the lowest and highest possible IDs.
+	Start string
+	End   string
+
+	// Count is the maximum number of entries returned. If zero, 10 is
+	// used.
+	Count int
+
+	// Consumer only returns the entries of the given consumer.
+	Consumer string
+
+	// MinIdle only returns entries that have been idle for at least
+	// MinIdle. It requires redis 6.2 or newer.
+	MinIdle time.Duration
+}
+
+// ms converts d to milliseconds, as used by the arguments of commands.
+func ms(d time.Duration) int {
+	return int(d / time.Millisecond)
+}
+
+// iface2entry converts an entry of a stream, [id, [field, value, ...]].
+func iface2entry(a interface{}) (StreamEntry, error) {
+	r, ok := a.([]interface{})
+	if !ok || len(r) != 2 {
+		return StreamEntry{}, ErrServerError
+	}
+	id, ok := r[0].(string)
+	if !ok {
+		return StreamEntry{}, ErrServerError
+	}
+	e := StreamEntry{ID: id}
+	switch r[1].(type) {
+	case []interface{}:
+		e.Fields = iface2strmap(r[1])
+	case nil:
+		// Entry deleted while pending in a consumer group.
+	default:
+		return StreamEntry{}, ErrServerError
+	}
+	return e, nil
+}
+
+// iface2entries converts a list of stream entries.
+func iface2entries(a interface{}) ([]StreamEntry, error) {
+	if a == nil {
+		return []StreamEntry{}, nil
+	}
+	r, ok := a.([]interface{})
+	if !ok {
+		return nil, ErrServerError
+	}
+	entries := make([]StreamEntry, 0, len(r))
+	for _, v := range r {
+		if v == nil {
+			// Deleted entry claimed by XCLAIM, before redis 7.0.
+			continue
+		}
+		e, err := iface2entry(v)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, e)
+	}
+	return entries, nil
+}
+
+// iface2xstreams converts the reply of XREAD and XREADGROUP.
+func iface2xstreams(a interface{}) ([]XStream, error) {
+	r, ok := a.([]interface{})
+	if !ok {
+		return nil, ErrServerError
+	}
+	streams := make([]XStream, len(r))
+	for n, v := range r {
+		s, ok := v.([]interface{})
+		if !ok || len(s) != 2 {
+			return nil, ErrServerError
+		}
+		key, ok := s[0].(string)
+		if !ok {
+			return nil, ErrServerError
+		}
+		entries, err := iface2entries(s[1])
+		if err != nil {
+			return nil, err
+		}
+		streams[n] = XStream{Stream: key, Entries: entries}
+	}
+	return streams, nil
+}
+
+// xread executes XREAD or XREADGROUP. The first arguments of the command
+// are given by a, followed by COUNT, BLOCK and STREAMS.
+// Streams are keys followed by IDs, and must be on the same server.
+func (c *Client) xread(a []interface{}, count int, block time.Duration, streams []string) ([]XStream, error) {
+	if len(streams) == 0 || len(streams)%2 != 0 {
+		return nil, errStreamArgs
+	}
+	cmd := a[0].(string)
+	srv, err := c.pickServerForKeys(cmd, streams[:len(streams)/2])
+	if err != nil {
+		return nil, err
+	}
+	if count > 0 {
+		a = append(a, "COUNT", count)
+	}
+	if block > 0 {
+		n := ms(block)
+		if n == 0 {
+			n = 1 // BLOCK 0 blocks forever.
+		}
+		a = append(a, "BLOCK", n)
+	}
+	a = append(a, "STREAMS")
+	a = append(a, vstr2iface(streams)...)
+	v, err := c.execWithAddrTimeout(true, srv, block, a...)
+	if err != nil {
+		return nil, err
+	}
+	if v == nil {
+		if block > 0 {
+			return nil, ErrTimedOut
+		}
+		return []XStream{}, nil
+	}
+	return iface2xstreams(v)
+}
+
+// http://redis.io/commands/xack
+// XAck acknowledges entries of a consumer group, and returns the number
+// of entries acknowledged.
+func (c *Client) XAck(key, group string, ids ...string) (int, error) {
+	a := append([]interface{}{group}, vstr2iface(ids)...)
+	v, err := c.execWithKey(true, "XACK", key, a...)
+	if err != nil {
+		return 0, err
+	}
+	return iface2int(v)
+}
+
+// http://redis.io/commands/xadd
+// XAdd appends an entry to a stream and returns its ID. If id is empty,
+// redis generates it. If trim is not nil, the stream is trimmed.
+// Fields are sent in alphabetical order.
+func (c *Client) XAdd(key, id string, fields map[string]string, trim *XTrimOptions) (string, error) {
+	var a []interface{}
+	if trim != nil {
+		a = trim.args()
+	}
+	if id == "" {
+		id = "*"
+	}
+	a = append(a, id)
+	names := make([]string, 0, len(fields))
+	for k := range fields {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	for _, k := range names {
+		a = append(a, k, fields[k])
+	}
+	v, err := c.execWithKey(true, "XADD", key, a...)
+	if err != nil {
+		return "", err
+	}
+	return iface2str(v)
+}
+
+// http://redis.io/commands/xautoclaim
+// XAutoClaim transfers to consumer up to count entries of a consumer
+// group that have been pending for at least minIdle, starting at ID start.
+// It returns the ID to start the next call with, which is "0-0" when all
+// pending entries were scanned. It requires redis 6.2 or newer.
+func (c *Client) XAutoClaim(key, group, consumer string, minIdle time.Duration, start string, count int) (next string, entries []StreamEntry, err error) {
+	a := []interface{}{group, consumer, ms(minIdle), start}
+	if count > 0 {
+		a = append(a, "COUNT", count)
+	}
+	v, err := c.execWithKey(true, "XAUTOCLAIM", key, a...)
+	if err != nil {
+		return
+	}
+	// Redis 7.0 adds a third item, the IDs of deleted entries.
+	r, ok := v.([]interface{})
+	if !ok || len(r) < 2 {
+		err = ErrServerError
+		return
+	}
+	if next, err = iface2str(r[0]); err != nil {
+		return
+	}
+	entries, err = iface2entries(r[1])
+	return
+}
+
+// http://redis.io/commands/xclaim
+// XClaim transfers to consumer the given entries of a consumer group,
+// if they have been pending for at least minIdle, and returns them.
+func (c *Client) XClaim(key, group, consumer string, minIdle time.Duration, ids ...string) ([]StreamEntry, error) {
+	a := append([]interface{}{group, consumer, ms(minIdle)}, vstr2iface(ids)...)
+	v, err := c.execWithKey(true, "XCLAIM", key, a...)
+	if err != nil {
+		return nil, err
+	}
+	return iface2entries(v)
+}
+
+// http://redis.io/commands/xdel
+// XDel removes entries from a stream, and returns the number of entries
+// removed.
+func (c *Client) XDel(key string, ids ...string) (int, error) {
+	v, err := c.execWithKey(true, "XDEL", key, vstr2iface(ids)...)
+	if err != nil {
+		return 0, err
+	}
+	return iface2int(v)
+}
+
+// xgroup executes an XGROUP subcommand on the server of key.
+func (c *Client) xgroup(sub, key string, a ...interface{}) (interface{}, error) {
+	srv, err := c.pickServer("XGROUP", key)
+	if err != nil {
+		return nil, err
+	}
+	x := []interface{}{"XGROUP", sub, key}
+	return c.execWithAddr(true, srv, append(x, a...)...)
+}
+
+// http://redis.io/commands/xgroup-create
+// XGroupCreate creates a consumer group that starts reading the stream
+// after the given ID. Use "$" for new entries only, or "0" for all of them.
+// If mkstream is set, the stream is created if it doesn't exist.
+func (c *Client) XGroupCreate(key, group, id string, mkstream bool) error {
+	a := []interface{}{group, id}
+	if mkstream {
+		a = append(a, "MKSTREAM")
+	}
+	_, err := c.xgroup("CREATE", key, a...)
+	return err
+}
+
+// http://redis.io/commands/xgroup-delconsumer
+// XGroupDelConsumer removes a consumer from a consumer group, and returns
+// the number of entries it had pending, which are lost.
+func (c *Client) XGroupDelConsumer(key, group, consumer string) (int, error) {
+	v, err := c.xgroup("DELCONSUMER", key, group, consumer)
+	if err != nil {
+		return 0, err
+	}
+	return iface2int(v)
+}
+
+// http://redis.io/commands/xgroup-destroy
+// XGroupDestroy removes a consumer group, and returns true if it existed.
+func (c *Client) XGroupDestroy(key, group string) (bool, error) {
+	v, err := c.xgroup("DESTROY", key, group)
+	if err != nil {
+		return false, err
+	}
+	return iface2bool(v)
+}
+
+// http://redis.io/commands/xgroup-setid
+// XGroupSetID sets the last delivered ID of a consumer group.
+func (c *Client) XGroupSetID(key, group, id string) error {
+	_, err := c.xgroup("SETID", key, group, id)
+	return err
+}
+
+// http://redis.io/commands/xlen
+func (c *Client) XLen(key string) (int, error) {
+	v, err := c.execWithKey(true, "XLEN", key)
+	if err != nil {
+		return 0, err
+	}
+	return iface2int(v)
+}
+
+// http://redis.io/commands/xpending
+// XPending returns the summary of the pending entries of a consumer group.
+func (c *Client) XPending(key, group string) (*XPending, error) {
+	v, err := c.execWithKey(true, "XPENDING", key, group)
+	if err != nil {
+		return nil, err
+	}
+	r, ok := v.([]interface{})
+	if !ok || len(r) != 4 {
+		return nil, ErrServerError
+	}
+	p := &XPending{Consumers: make(map[string]int)}
+	if p.Count, err = iface2int(r[0]); err != nil {
+		return nil, err
+	}
+	if p.Count == 0 {
+		return p, nil
+	}
+	if p.Lower, err = iface2str(r[1]); err != nil {
+		return nil, err
+	}
+	if p.Higher, err = iface2str(r[2]); err != nil {
+		return nil, err
+	}
+	consumers, ok := r[3].([]interface{})
+	if !ok {
+		return nil, ErrServerError
+	}
+	for _, item := range consumers {
+		cv := iface2vstr(item)
+		if len(cv) != 2 {
+			return nil, ErrServerError
+		}
+		if p.Consumers[cv[0]], err = strconv.Atoi(cv[1]); err != nil {
+			return nil, err
+		}
+	}
+	return p, nil
+}
+
+// http://redis.io/commands/xpending
+// XPendingRange returns the pending entries of a consumer group.
+func (c *Client) XPendingRange(key, group string, opts XPendingOptions) ([]XPendingEntry, error) {
+	a := []interface{}{group}
+	if opts.MinIdle > 0 {
+		a = append(a, "IDLE", ms(opts.MinIdle))
+	}
+	start, end, count := opts.Start, opts.End, opts.Count
+	if start == "" {
+		start = "-"
+	}
+	if end == "" {
+		end = "+"
+	}
+	if count <= 0 {
+		count = 10
+	}
+	a = append(a, start, end, count)
+	if opts.Consumer != "" {
+		a = append(a, opts.Consumer)
+	}
+	v, err := c.execWithKey(true, "XPENDING", key, a...)
+	if err != nil {
+		return nil, err
+	}
+	if v == nil {
+		return []XPendingEntry{}, nil
+	}
+	r, ok := v.([]interface{})
+	if !ok {
+		return nil, ErrServerError
+	}
+	entries := make([]XPendingEntry, len(r))
+	for n, item := range r {
+		e, ok := item.([]interface{})
+		if !ok || len(e) != 4 {
+			return nil, ErrServerError
+		}
+		id, err := iface2str(e[0])
+		if err != nil {
+			return nil, err
+		}
+		consumer, err := iface2str(e[1])
+		if err != nil {
+			return nil, err
+		}
+		idle, err := iface2int(e[2])
+		if err != nil {
+			return nil, err
+		}
+		deliveries, err := iface2int(e[3])
+		if err != nil {
+			return nil, err
+		}
+		entries[n] = XPendingEntry{
+			ID:         id,
+			Consumer:   consumer,
+			Idle:       time.Duration(idle) * time.Millisecond,
+			Deliveries: deliveries,
+		}
+	}
+	return entries, nil
+}
+
+// http://redis.io/commands/xrange
+// XRange returns the entries with IDs between start and end, inclusive.
+// Use "-" and "+" for the lowest and highest possible IDs. If count is
+// greater than zero, at most count entries are returned.
+func (c *Client) XRange(key, start, end string, count int) ([]StreamEntry, error) {
+	return c.xrange("XRANGE", key, start, end, count)
+}
+
+func (c *Client) xrange(cmd, key, from, to string, count int) ([]StreamEntry, error) {
+	a := []interface{}{from, to}
+	if count > 0 {
+		a = append(a, "COUNT", count)
+	}
+	v, err := c.execWithKey(true, cmd, key, a...)
+	if err != nil {
+		return nil, err
+	}
+	return iface2entries(v)
+}
+
+// http://redis.io/commands/xread
+// XRead reads entries with IDs greater than the given ones from one or
+// more streams. Streams are keys followed by their IDs, like the STREAMS
+// argument of XREAD, e.g. XRead(0, 0, "s1", "s2", "0", "$"). The keys
+// must be on the same server.
+//
+// If block is greater than zero, XRead waits up to block for new entries
+// and returns ErrTimedOut if there are none. The socket read timeout is
+// extended by block, like BLPop does. Redis only supports milliseconds
+// resolution, and blocking indefinitely is not supported.
+func (c *Client) XRead(count int, block time.Duration, streams ...string) ([]XStream, error) {
+	return c.xread([]interface{}{"XREAD"}, count, block, streams)
+}
+
+// http://redis.io/commands/xreadgroup
+// XReadGroup reads entries from one or more streams as consumer of a
+// consumer group. Use the ID ">" for entries never delivered to other
+// consumers, or any other ID for the entries pending for this consumer.
+// Entries are pending until acknowledged with XAck, unless noack is set.
+// Streams, count and block are like in XRead.
+func (c *Client) XReadGroup(group, consumer string, count int, block time.Duration, noack bool, streams ...string) ([]XStream, error) {
+	a := []interface{}{"XREADGROUP", "GROUP", group, consumer}
+	if noack {
+		a = append(a, "NOACK")
+	}
+	return c.xread(a, count, block, streams)
+}
+
+// http://redis.io/commands/xrevrange
+// XRevRange is like XRange, in reverse order. Note that end comes first.
+func (c *Client) XRevRange(key, end, start string, count int) ([]StreamEntry, error) {
+	return c.xrange("XREVRANGE", key, end, start, count)
+}
+
+// http://redis.io/commands/xtrim
+// XTrim trims a stream, and returns the number of entries removed.
+func (c *Client) XTrim(key string, trim XTrimOptions) (int, error) {
+	v, err := c.execWithKey(true, "XTRIM", key, trim.args()...)
+	if err != nil {
+		return 0, err
+	}
+	return iface2int(v)
+}
@@ -0,0 +1,289 @@
+// Copyright 2013-2014 go-redis authors.  All rights reserved.
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+package redis
+
+import (
+	"errors"
+	"reflect"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestXTrimOptionsArgs(t *testing.T) {
+	tests := []struct {
+		opts XTrimOptions
+		args []interface{}
+	}{
+		{XTrimOptions{MaxLen: 10}, []interface{}{"MAXLEN", "=", 10}},
+		{XTrimOptions{MaxLen: 10, Approx: true, Limit: 5},
+			[]interface{}{"MAXLEN", "~", 10, "LIMIT", 5}},
+		{XTrimOptions{MinID: "5-0", Limit: 5}, []interface{}{"MINID", "=", "5-0"}},
+	}
+	for _, test := range tests {
+		if a := test.opts.args(); !reflect.DeepEqual(a, test.args) {
+			t.Fatalf(errUnexpected, a)
+		}
+	}
+}
+
+func TestIface2XStreams(t *testing.T) {
+	v := []interface{}{
+		[]interface{}{"s1", []interface{}{
+			[]interface{}{"1-0", []interface{}{"a", "1", "b", "2"}},
+			[]interface{}{"2-0", nil},
+		}},
+	}
+	r, err := iface2xstreams(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []XStream{{"s1", []StreamEntry{
+		{"1-0", map[string]string{"a": "1", "b": "2"}},
+		{"2-0", nil},
+	}}}
+	if !reflect.DeepEqual(r, want) {
+		t.Fatalf(errUnexpected, r)
+	}
+	if _, err = iface2xstreams([]interface{}{"s1"}); err != ErrServerError {
+		t.Fatalf(errUnexpected, err)
+	}
+}
+
+func TestXAddRange(t *testing.T) {
+	k := randomString(16)
+	defer rc.Del(k)
+	for n := 1; n <= 5; n++ {
+		id, err := rc.XAdd(k, strconv.Itoa(n)+"-0",
+			map[string]string{"n": strconv.Itoa(n)}, nil)
+		if err != nil {
+			t.Fatal(err)
+		} else if id != strconv.Itoa(n)+"-0" {
+			t.Fatalf(errUnexpected, id)
+		}
+	}
+	id, err := rc.XAdd(k, "", map[string]string{"n": "6"},
+		&XTrimOptions{MaxLen: 4})
+	if err != nil {
+		t.Fatal(err)
+	} else if id == "" {
+		t.Fatalf(errUnexpected, id)
+	}
+	if n, err := rc.XLen(k); err != nil {
+		t.Fatal(err)
+	} else if n != 4 {
+		t.Fatalf(errUnexpected, n)
+	}
+	r, err := rc.XRange(k, "-", "+", 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []StreamEntry{
+		{"3-0", map[string]string{"n": "3"}},
+		{"4-0", map[string]string{"n": "4"}},
+	}
+	if !reflect.DeepEqual(r, want) {
+		t.Fatalf(errUnexpected, r)
+	}
+	r, err = rc.XRevRange(k, "+", "-", 0)
+	if err != nil {
+		t.Fatal(err)
+	} else if len(r) != 4 || r[0].ID != id || r[3].ID != "3-0" {
+		t.Fatalf(errUnexpected, r)
+	}
+	if n, err := rc.XDel(k, "3-0", "100-0"); err != nil {
+		t.Fatal(err)
+	} else if n != 1 {
+		t.Fatalf(errUnexpected, n)
+	}
+	if n, err := rc.XTrim(k, XTrimOptions{MinID: "5-0"}); err != nil {
+		t.Fatal(err)
+	} else if n != 1 {
+		t.Fatalf(errUnexpected, n)
+	}
+}
+
+func TestXRead(t *testing.T) {
+	tag := "{" + randomString(16) + "}"
+	k1, k2 := tag+"a", tag+"b"
+	defer rc.Del(k1, k2)
+	if _, err := rc.XAdd(k1, "1-0", map[string]string{"a": "1"}, nil); err != nil {
+		t.Fatal(err)
+	}
+	r, err := rc.XRead(0, 0, k1, k2, "0", "0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []XStream{{k1, []StreamEntry{{"1-0", map[string]string{"a": "1"}}}}}
+	if !reflect.DeepEqual(r, want) {
+		t.Fatalf(errUnexpected, r)
+	}
+	start := time.Now()
+	if _, err = rc.XRead(0, 100*time.Millisecond, k1, "$"); err != ErrTimedOut {
+		t.Fatalf(errUnexpected, err)
+	} else if time.Since(start) < 100*time.Millisecond {
+		t.Fatal("XRead returned before the block timeout")
+	}
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		rc.XAdd(k2, "5-0", map[string]string{"b": "2"}, nil)
+	}()
+	r, err = rc.XRead(1, time.Second, k1, k2, "$", "$")
+	if err != nil {
+		t.Fatal(err)
+	} else if len(r) != 1 || r[0].Stream != k2 || r[0].Entries[0].ID != "5-0" {
+		t.Fatalf(errUnexpected, r)
+	}
+	if _, err = rc.XRead(0, 0, k1); err != errStreamArgs {
+		t.Fatalf(errUnexpected, err)
+	}
+}
+
+func TestXReadCrossShard(t *testing.T) {
+	c := New("127.0.0.1:6379", "127.0.0.1:6379 db=1")
+	keys := keysOnDistinctServers(t, c, "stream", 2)
+	if _, err := c.XRead(0, 0, keys[0], keys[1], "0", "0"); err != ErrCrossShard {
+		t.Fatalf(errUnexpected, err)
+	}
+}
+
+func TestXGroup(t *testing.T) {
+	k := randomString(16)
+	defer rc.Del(k)
+	if err := rc.XGroupCreate(k, "g", "$", true); err != nil {
+		t.Fatal(err)
+	}
+	if err := rc.XGroupCreate(k, "g", "$", true); err == nil {
+		t.Fatal("XGroupCreate succeeded for an existing group")
+	}
+	for n := 1; n <= 3; n++ {
+		id := strconv.Itoa(n) + "-0"
+		if _, err := rc.XAdd(k, id, map[string]string{"n": strconv.Itoa(n)}, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	r, err := rc.XReadGroup("g", "c1", 2, 0, false, k, ">")
+	if err != nil {
+		t.Fatal(err)
+	} else if len(r) != 1 || len(r[0].Entries) != 2 || r[0].Entries[1].ID != "2-0" {
+		t.Fatalf(errUnexpected, r)
+	}
+	p, err := rc.XPending(k, "g")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &XPending{2, "1-0", "2-0", map[string]int{"c1": 2}}
+	if !reflect.DeepEqual(p, want) {
+		t.Fatalf(errUnexpected, p)
+	}
+	pe, err := rc.XPendingRange(k, "g", XPendingOptions{Consumer: "c1"})
+	if err != nil {
+		t.Fatal(err)
+	} else if len(pe) != 2 || pe[0].ID != "1-0" || pe[0].Consumer != "c1" || pe[0].Deliveries != 1 {
+		t.Fatalf(errUnexpected, pe)
+	}
+	if n, err := rc.XAck(k, "g", "1-0"); err != nil {
+		t.Fatal(err)
+	} else if n != 1 {
+		t.Fatalf(errUnexpected, n)
+	}
+	e, err := rc.XClaim(k, "g", "c2", 0, "2-0")
+	if err != nil {
+		t.Fatal(err)
+	} else if len(e) != 1 || e[0].ID != "2-0" {
+		t.Fatalf(errUnexpected, e)
+	}
+	next, e, err := rc.XAutoClaim(k, "g", "c3", 0, "0-0", 10)
+	if err != nil {
+		t.Fatal(err)
+	} else if next != "0-0" || len(e) != 1 || e[0].Fields["n"] != "2" {
+		t.Fatalf(errUnexpected, e)
+	}
+	if n, err := rc.XGroupDelConsumer(k, "g", "c3"); err != nil {
+		t.Fatal(err)
+	} else if n != 1 {
+		t.Fatalf(errUnexpected, n)
+	}
+	if err = rc.XGroupSetID(k, "g", "0"); err != nil {
+		t.Fatal(err)
+	}
+	r, err = rc.XReadGroup("g", "c1", 0, 0, true, k, ">")
+	if err != nil {
+		t.Fatal(err)
+	} else if len(r) != 1 || len(r[0].Entries) != 3 {
+		t.Fatalf(errUnexpected, r)
+	}
+	if ok, err := rc.XGroupDestroy(k, "g"); err != nil {
+		t.Fatal(err)
+	} else if !ok {
+		t.Fatalf(errUnexpected, ok)
+	}
+}
+
+func TestConsumer(t *testing.T) {
+	k := randomString(16)
+	defer rc.Del(k)
+	var (
+		lk     sync.Mutex
+		seen   = make(map[string]int)
+		failed bool
+	)
+	done := make(chan bool)
+	cs := rc.NewConsumer(k, "g", "test", func(e StreamEntry) error {
+		lk.Lock()
+		defer lk.Unlock()
+		if e.ID == "5-0" && !failed {
+			failed = true
+			return errors.New("failed")
+		}
+		seen[e.ID]++
+		if len(seen) == 10 {
+			close(done)
+		}
+		return nil
+	})
+	cs.Workers = 3
+	cs.Block = 50 * time.Millisecond
+	cs.MinIdle = 50 * time.Millisecond
+	for n := 1; n <= 10; n++ {
+		id := strconv.Itoa(n) + "-0"
+		if _, err := rc.XAdd(k, id, map[string]string{"n": id}, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := cs.Start(); err != nil {
+		t.Fatal(err)
+	}
+	if err := cs.Start(); err != ErrConsumerStarted {
+		t.Fatalf(errUnexpected, err)
+	}
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("entries were not processed")
+	}
+	cs.Close()
+	for id, n := range seen {
+		if n != 1 {
+			t.Fatalf(errUnexpected, id)
+		}
+	}
+	if p, err := rc.XPending(k, "g"); err != nil {
+		t.Fatal(err)
+	} else if p.Count != 0 {
+		t.Fatalf(errUnexpected, p)
+	}
+}
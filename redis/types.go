@@ -18,8 +18,6 @@ import (
 	"errors"
 	"fmt"
 	"strconv"
-    // "log"
-    // "reflect"
 )
 
 var ErrInvalidType = errors.New("Invalid type for convertion")
@@ -33,7 +31,8 @@ func vstr2iface(a []string) (r []interface{}) {
 	return
 }
 
-// iface2vstr converts an interface to an array of strings
+// iface2vstr converts an interface to an array of strings.
+// Nil items are converted to empty strings.
 func iface2vstr(a interface{}) []string {
 	r := []string{}
 	switch a.(type) {
@@ -42,12 +41,35 @@ func iface2vstr(a interface{}) []string {
 			switch item.(type) {
 			case string:
 				r = append(r, item.(string))
+			case nil:
+				r = append(r, "")
 			}
 		}
 	}
 	return r
 }
 
+// iface2vint converts an interface to an array of ints.
+// Empty arrays, which are parsed as nil, are converted to empty slices.
+func iface2vint(a interface{}) ([]int, error) {
+	if a == nil {
+		return []int{}, nil
+	}
+	items, ok := a.([]interface{})
+	if !ok {
+		return nil, ErrInvalidType
+	}
+	r := make([]int, len(items))
+	for n, item := range items {
+		i, err := iface2int(item)
+		if err != nil {
+			return nil, err
+		}
+		r[n] = i
+	}
+	return r, nil
+}
+
 // iface2strmap converts an interface to map of strings
 func iface2strmap(a interface{}) map[string]string {
 	tmp := iface2vstr(a)
@@ -58,26 +80,35 @@ func iface2strmap(a interface{}) map[string]string {
 	return m
 }
 
-// iface2strmap converts an interface to map of strings
-func iface2scanres(a interface{}) []string {
-	
-	r := []string{}
-	switch a.(type) {
-	case []interface{}:
-		for _, item := range a.([]interface{}) {
-            //log.Println("type of item - ",key,reflect.TypeOf(item))
-			switch item.(type) {
-			case string:
-				r = append(r, item.(string))
-            case []interface{}:
-                r = append(r,iface2vstr(item)...)
-			}
+// iface2zslice converts an array of members and scores to an array of Z.
+func iface2zslice(a interface{}) ([]Z, error) {
+	tmp := iface2vstr(a)
+	if len(tmp)%2 != 0 {
+		return nil, ErrInvalidType
+	}
+	r := make([]Z, len(tmp)/2)
+	for n := range r {
+		score, err := strconv.ParseFloat(tmp[(n*2)+1], 64)
+		if err != nil {
+			return nil, err
 		}
+		r[n] = Z{Member: tmp[n*2], Score: score}
 	}
-    
-	return r
+	return r, nil
 }
 
+// iface2score converts a score to float64. Nil replies are returned as
+// ErrNil.
+func iface2score(a interface{}) (float64, error) {
+	if a == nil {
+		return 0, ErrNil
+	}
+	s, err := iface2str(a)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseFloat(s, 64)
+}
 
 // iface2bool validates and converts interface (int) to bool
 func iface2bool(a interface{}) (bool, error) {
@@ -101,15 +132,31 @@ func iface2int(a interface{}) (int, error) {
 	return 0, ErrInvalidType
 }
 
-// iface2str validates and converts interface to string
+// iface2str validates and converts interface to string.
+// Nil replies are converted to empty strings.
 func iface2str(a interface{}) (string, error) {
 	switch a.(type) {
 	case string:
 		return a.(string), nil
+	case nil:
+		return "", nil
 	}
 	return "", ErrInvalidType
 }
 
+// checkArgs returns an error if any of the arguments is of a type that
+// autoconv_args does not support.
+func checkArgs(a []interface{}) error {
+	for _, item := range a {
+		switch item.(type) {
+		case int, int64, uint64, float64, string, []byte:
+		default:
+			return fmt.Errorf("Unsupported argument type for convertion: %T", item)
+		}
+	}
+	return nil
+}
+
 // autoconv_args converts commands' arguments from multiple types to string,
 // so they can be sent to the server. e.g. rc.IncrBy("k", 1) -> "k", "1"
 func autoconv_args(a []interface{}) []string {
@@ -118,8 +165,16 @@ func autoconv_args(a []interface{}) []string {
 		switch item.(type) {
 		case int:
 			s[n] = strconv.Itoa(item.(int))
+		case int64:
+			s[n] = strconv.FormatInt(item.(int64), 10)
+		case uint64:
+			s[n] = strconv.FormatUint(item.(uint64), 10)
+		case float64:
+			s[n] = strconv.FormatFloat(item.(float64), 'f', -1, 64)
 		case string:
 			s[n] = item.(string)
+		case []byte:
+			s[n] = string(item.([]byte))
 		default:
 			// TODO: use iface2n, maybe
 			panic(fmt.Sprintf("Unsupported argument type for convertion: %#v", item))